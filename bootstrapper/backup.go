@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+)
+
+const backupStamp = "2006.01.02"
+
+// backup is the dated folder a command moves or copies the files it
+// replaces into. The folder is created on first use and shared by
+// everything the command backs up, so the secrets and configs of one
+// operation can be restored together.
+type backup struct {
+	talosDir string
+	dir      string
+}
+
+func newBackup(talosDir string) *backup {
+	return &backup{talosDir: talosDir}
+}
+
+func (b *backup) folder() (string, error) {
+	if b.dir != "" {
+		return b.dir, nil
+	}
+
+	dir := uniqueDir(filepath.Join(b.talosDir, time.Now().Format(backupStamp)))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	b.dir = dir
+	return dir, nil
+}
+
+func performBackup(b *backup, clusterName string) error {
+	pattern := clusterName + "-*.yaml"
+	if !needBackup(b.talosDir, pattern) {
+		return nil
+	}
+
+	final, err := b.folder()
+	if err != nil {
+		return err
+	}
+	moveIfExists(filepath.Join(b.talosDir, "config"), filepath.Join(final, "config"))
+	moveGlob(b.talosDir, pattern, final)
+	fmt.Printf("backed up previous configs to %s\n", final)
+
+	return nil
+}
+
+// backupSecrets copies the secrets file into the backup folder so it can be
+// recovered after a rotation.
+func backupSecrets(b *backup, src string) error {
+	data, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	final, err := b.folder()
+	if err != nil {
+		return err
+	}
+	if err := cluster.WriteFile(filepath.Join(final, filepath.Base(src)), data); err != nil {
+		return err
+	}
+	fmt.Printf("backed up previous secrets to %s\n", final)
+
+	return nil
+}
+
+func listBackups(talosDir string) ([]string, error) {
+	entries, err := os.ReadDir(talosDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		if !e.IsDir() || len(e.Name()) < len(backupStamp) {
+			continue
+		}
+		if _, err := time.Parse(backupStamp, e.Name()[:len(backupStamp)]); err != nil {
+			continue
+		}
+		backups = append(backups, e.Name())
+	}
+	sort.Strings(backups)
+
+	return backups, nil
+}
+
+// restoreBackup copies every file from the named backup back into the
+// output directory, and a secrets file to the store's path. The current
+// secrets are backed up first. The current configs are only moved aside,
+// into the same backup folder, when the backup holds configs to replace
+// them.
+func restoreBackup(store secretStore, clusterName, name string) error {
+	b := store.backup
+	src := filepath.Join(b.talosDir, name)
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", name, err)
+	}
+
+	var (
+		files   []string
+		configs bool
+	)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		files = append(files, e.Name())
+		if matched, _ := filepath.Match(clusterName+"-*.yaml", e.Name()); matched || e.Name() == "config" {
+			configs = true
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("backup %s holds no files", name)
+	}
+
+	if err := backupSecrets(b, store.path); err != nil {
+		return fmt.Errorf("failed to back up secrets: %w", err)
+	}
+	if configs {
+		if err := performBackup(b, clusterName); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(src, f))
+		if err != nil {
+			return err
+		}
+
+		dst := filepath.Join(b.talosDir, f)
+		if f == filepath.Base(store.path) {
+			dst = store.path
+			if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+				return err
+			}
+		}
+		if err := cluster.WriteFile(dst, data); err != nil {
+			return err
+		}
+		fmt.Printf("restored %s\n", dst)
+	}
+
+	return nil
+}
+
+func needBackup(dir, pattern string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "config")); err == nil {
+		return true
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+	return len(matches) > 0
+}
+
+func uniqueDir(base string) string {
+	if _, err := os.Stat(base); os.IsNotExist(err) {
+		return base
+	}
+	for i := 1; ; i++ {
+		cand := fmt.Sprintf("%s.%d", base, i)
+		if _, err := os.Stat(cand); os.IsNotExist(err) {
+			return cand
+		}
+	}
+}
+
+func moveIfExists(src, dst string) {
+	if fi, err := os.Stat(src); err == nil && !fi.IsDir() {
+		os.Rename(src, dst)
+	}
+}
+
+func moveGlob(root, pattern, dstDir string) {
+	files, err := filepath.Glob(filepath.Join(root, pattern))
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		target := filepath.Join(dstDir, filepath.Base(f))
+		os.Rename(f, target)
+	}
+}
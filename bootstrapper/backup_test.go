@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreBackupKeepsRotatedSecrets(t *testing.T) {
+	for name, secretsPath := range map[string]string{
+		"output directory": "",
+		"secrets.path":     filepath.Join(t.TempDir(), "secrets", "cluster.json.age"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			outDir := t.TempDir()
+			store := secretStore{path: filepath.Join(outDir, secretsFile), backup: newBackup(outDir)}
+			if secretsPath != "" {
+				store.path = secretsPath
+				require.NoError(t, os.MkdirAll(filepath.Dir(store.path), 0o700))
+			}
+
+			require.NoError(t, os.WriteFile(store.path, []byte("original"), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(outDir, "test-cluster-cp1-controlplane.yaml"), []byte("original config"), 0o600))
+			previous := newBackup(outDir)
+			require.NoError(t, backupSecrets(previous, store.path))
+			require.NoError(t, performBackup(previous, "test-cluster"))
+
+			backups, err := listBackups(outDir)
+			require.NoError(t, err)
+			require.Len(t, backups, 1)
+
+			// secrets rotate: the live file now holds secrets no backup has.
+			require.NoError(t, os.WriteFile(store.path, []byte("rotated"), 0o600))
+
+			require.NoError(t, restoreBackup(store, "test-cluster", backups[0]))
+
+			data, err := os.ReadFile(store.path)
+			require.NoError(t, err)
+			assert.Equal(t, "original", string(data))
+
+			after, err := listBackups(outDir)
+			require.NoError(t, err)
+			require.Len(t, after, 2)
+			data, err = os.ReadFile(filepath.Join(store.backup.dir, filepath.Base(store.path)))
+			require.NoError(t, err)
+			assert.Equal(t, "rotated", string(data))
+		})
+	}
+}
+
+func TestRestoreSecretsOnlyBackupKeepsConfigs(t *testing.T) {
+	outDir := t.TempDir()
+	store := secretStore{path: filepath.Join(outDir, secretsFile), backup: newBackup(outDir)}
+	config := filepath.Join(outDir, "test-cluster-cp1-controlplane.yaml")
+
+	require.NoError(t, os.WriteFile(store.path, []byte("original"), 0o600))
+	require.NoError(t, backupSecrets(newBackup(outDir), store.path))
+	backups, err := listBackups(outDir)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	require.NoError(t, os.WriteFile(store.path, []byte("rotated"), 0o600))
+	require.NoError(t, os.WriteFile(config, []byte("current config"), 0o600))
+
+	require.NoError(t, restoreBackup(store, "test-cluster", backups[0]))
+
+	data, err := os.ReadFile(store.path)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+	data, err = os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Equal(t, "current config", string(data))
+
+	after, err := listBackups(outDir)
+	require.NoError(t, err)
+	assert.Len(t, after, 2)
+}
+
+func TestRestoreEmptyBackupFails(t *testing.T) {
+	outDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(outDir, "2024.01.01"), 0o700))
+	store := secretStore{path: filepath.Join(outDir, secretsFile), backup: newBackup(outDir)}
+
+	assert.Error(t, restoreBackup(store, "test-cluster", "2024.01.01"))
+}
+
+func TestGenerateNewSecretsUsesOneBackup(t *testing.T) {
+	for i := 1; i <= 4; i++ {
+		t.Setenv(fmt.Sprintf("NODE%d", i), fmt.Sprintf("192.168.50.1%d", i))
+	}
+	outDir := t.TempDir()
+	o := &options{specPath: "cluster.yaml", outDir: outDir}
+	require.NoError(t, runSecretsInit(o, nil))
+	require.NoError(t, runGenerate(o, nil))
+
+	o.newSecrets = true
+	require.NoError(t, runGenerate(o, nil))
+
+	backups, err := listBackups(outDir)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.FileExists(t, filepath.Join(outDir, backups[0], secretsFile))
+	assert.FileExists(t, filepath.Join(outDir, backups[0], "config"))
+}
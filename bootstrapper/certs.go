@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+)
+
+// runCertsStatus prints every certificate in the secrets file and the
+// talosconfigs in the output directory. It returns errExpiring when any of
+// them expires within the threshold, so it can back a cron alert.
+func runCertsStatus(o *options, _ []string) error {
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+
+	clusterSecrets, err := loadClusterSecrets(store)
+	if err != nil {
+		return fmt.Errorf("failed to load cluster secrets: %w", err)
+	}
+
+	certs, err := clusterSecrets.Certificates()
+	if err != nil {
+		return err
+	}
+
+	talosconfigs, err := filepath.Glob(filepath.Join(o.outDir, "talosconfig-*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range append([]string{filepath.Join(o.outDir, "config")}, talosconfigs...) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		tcCerts, err := cluster.TalosconfigCertificates(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, tcCerts...)
+	}
+
+	now := time.Now()
+	threshold := time.Duration(o.thresholdDays) * 24 * time.Hour
+	expiring := 0
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSUBJECT\tISSUER\tKEY\tNOT BEFORE\tNOT AFTER\tDAYS\tSANS")
+	for _, c := range certs {
+		days := fmt.Sprint(c.DaysRemaining(now))
+		if c.ExpiresWithin(now, threshold) {
+			days += " !"
+			expiring++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.Name,
+			orDash(c.Subject),
+			orDash(c.Issuer),
+			c.KeyType,
+			c.NotBefore.UTC().Format(time.DateOnly),
+			c.NotAfter.UTC().Format(time.DateOnly),
+			days,
+			orDash(strings.Join(c.SANs, ",")),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if r := clusterSecrets.Rotation; r != nil {
+		fmt.Printf("\nCA rotation of %s in the %s phase\n", joinCAs(r.CAs()), r.Phase)
+	}
+
+	if expiring > 0 {
+		return fmt.Errorf("%w: %d certificate(s) within %d days", errExpiring, expiring, o.thresholdDays)
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// renewableCerts are the leaf certificates certs renew can re-sign.
+var renewableCerts = map[string]func(cs *cluster.Secrets, o *options, policy cluster.CertPolicy) error{
+	"hubble": func(cs *cluster.Secrets, _ *options, policy cluster.CertPolicy) error {
+		return cs.RenewHubbleCert(policy.Hubble)
+	},
+	"admin": func(cs *cluster.Secrets, o *options, policy cluster.CertPolicy) error {
+		return cs.RenewAdminCert(withLifetime(policy.TalosClient, o.lifetime))
+	},
+}
+
+// runCertsRenew re-signs the named leaf certificates from their existing
+// CAs, then regenerates the outputs and saves the secrets. The previous
+// secrets file is backed up first.
+func runCertsRenew(o *options, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: bootstrapper certs renew [flags] hubble|admin...")
+		return errUsage
+	}
+	for _, name := range args {
+		if _, ok := renewableCerts[name]; !ok {
+			return fmt.Errorf("unknown certificate %q, expected hubble or admin", name)
+		}
+	}
+
+	cfg, clusterSecrets, store, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args {
+		if err := renewableCerts[name](clusterSecrets, o, store.certs); err != nil {
+			return err
+		}
+	}
+
+	if err := regenerate(o, cfg, store, clusterSecrets); err != nil {
+		return err
+	}
+
+	fmt.Printf("renewed %s and regenerated configs in %s\n", strings.Join(args, ", "), o.outDir)
+	return nil
+}
+
+// regenerate saves changed secrets and rewrites the outputs from them. The
+// previous secrets file is backed up first, and the talosconfig is merged
+// into the existing one with -merge.
+func regenerate(o *options, cfg cluster.Config, store secretStore, cs *cluster.Secrets) error {
+	cfg, err := cfg.WithSecrets(*cs)
+	if err != nil {
+		return err
+	}
+
+	if err := backupSecrets(store.backup, store.path); err != nil {
+		return fmt.Errorf("failed to back up secrets: %w", err)
+	}
+	if err := saveClusterSecrets(store, cs); err != nil {
+		return fmt.Errorf("failed to save cluster secrets: %w", err)
+	}
+	return writeOutputs(o, cfg, store.backup)
+}
+
+// runRotateBegin starts a CA rotation. The configs it writes trust the new
+// CAs next to the current ones, which keep issuing.
+func runRotateBegin(o *options, _ []string) error {
+	cas, err := cluster.ParseCAs(o.cas)
+	if err != nil {
+		return err
+	}
+
+	cfg, clusterSecrets, store, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+	if err := clusterSecrets.BeginCARotation(cas, store.certs); err != nil {
+		return err
+	}
+	if err := regenerate(o, cfg, store, clusterSecrets); err != nil {
+		return err
+	}
+
+	fmt.Printf("generated new %s CAs and regenerated configs in %s\n", joinCAs(cas), o.outDir)
+	fmt.Println("apply the configs to every node, then run `certs rotate switch`")
+	return nil
+}
+
+// runRotateSwitch makes the new CAs issue. The old ones stay trusted until
+// runRotateFinish.
+func runRotateSwitch(o *options, _ []string) error {
+	cfg, clusterSecrets, store, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+	policy := store.certs
+	policy.TalosClient = withLifetime(policy.TalosClient, o.lifetime)
+	if err := clusterSecrets.SwitchCAs(policy); err != nil {
+		return err
+	}
+	if err := regenerate(o, cfg, store, clusterSecrets); err != nil {
+		return err
+	}
+
+	cas := clusterSecrets.Rotation.CAs()
+	fmt.Printf("switched %s to the new CAs and regenerated configs in %s\n", joinCAs(cas), o.outDir)
+	if slices.Contains(cas, cluster.CAKubernetes) {
+		fmt.Println("re-run `kubeconfig` for a client certificate from the new kubernetes CA")
+	}
+	fmt.Println("apply the configs to every node and check it serves certificates from the new CAs, then run `certs rotate finish`")
+	return nil
+}
+
+// runRotateFinish ends a CA rotation. The configs it writes no longer
+// trust the old CAs.
+func runRotateFinish(o *options, _ []string) error {
+	cfg, clusterSecrets, store, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+
+	var cas []cluster.CA
+	if clusterSecrets.Rotation != nil {
+		cas = clusterSecrets.Rotation.CAs()
+	}
+	if err := clusterSecrets.FinishCARotation(); err != nil {
+		return err
+	}
+	if err := regenerate(o, cfg, store, clusterSecrets); err != nil {
+		return err
+	}
+
+	fmt.Printf("dropped the old %s CAs and regenerated configs in %s\n", joinCAs(cas), o.outDir)
+	fmt.Println("apply the configs to every node to complete the rotation")
+	return nil
+}
+
+func joinCAs(cas []cluster.CA) string {
+	names := make([]string, 0, len(cas))
+	for _, ca := range cas {
+		names = append(names, string(ca))
+	}
+	return strings.Join(names, ", ")
+}
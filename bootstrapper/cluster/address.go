@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// validateHost accepts an IPv4 or IPv6 address or an RFC 1123 DNS name.
+// Ports, URLs and dotted quads that are not valid IPs are rejected, since
+// the value ends up in endpoints and certificate SANs as-is.
+func validateHost(host string) error {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+
+	if strings.ContainsAny(host, ":/") {
+		return fmt.Errorf("%q must be an IP address or DNS name without port or scheme", host)
+	}
+
+	if len(host) > 253 {
+		return fmt.Errorf("%q is longer than 253 characters", host)
+	}
+
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	for _, label := range labels {
+		if !validLabel(label) {
+			return fmt.Errorf("%q is neither a valid IP address nor a valid DNS name", host)
+		}
+	}
+
+	// A DNS name never ends in an all-numeric label, so this is a mistyped
+	// IPv4 address such as 300.1.1.1.
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return fmt.Errorf("%q is not a valid IP address", host)
+	}
+
+	return nil
+}
+
+func validLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Config) nodes() []NodeConfig {
+	return append(append([]NodeConfig{}, c.controlPlanes...), c.workers...)
+}
+
+// validateClusterNetworks checks that no node subnet or address collides
+// with the pod or service networks. Prefixes of different address families
+// never overlap, so IPv4 and IPv6 are checked alike. Malformed CIDRs are
+// reported by validateNetworkSettings.
+func (c Config) validateClusterNetworks() error {
+	var err error
+	clusterNets := []struct {
+		kind  string
+		cidrs []string
+	}{
+		{"pod subnet", c.podSubnets()},
+		{"service subnet", c.serviceSubnets()},
+	}
+
+	for _, cn := range clusterNets {
+		for _, cidr := range cn.cidrs {
+			clusterNet, pErr := netip.ParsePrefix(cidr)
+			if pErr != nil {
+				continue
+			}
+
+			for _, n := range c.nodes() {
+				for _, s := range n.subnets() {
+					if nodeNet, nErr := netip.ParsePrefix(s); nErr == nil && nodeNet.Overlaps(clusterNet) {
+						err = errors.Join(err, fmt.Errorf("node %s: subnet %s overlaps %s %s", n.HostName, s, cn.kind, cidr))
+					}
+				}
+				if addr, aErr := netip.ParseAddr(n.Address); aErr == nil && clusterNet.Contains(addr) {
+					err = errors.Join(err, fmt.Errorf("node %s: address %s is inside %s %s", n.HostName, n.Address, cn.kind, cidr))
+				}
+			}
+		}
+	}
+
+	for _, pod := range c.podSubnets() {
+		podNet, pErr := netip.ParsePrefix(pod)
+		if pErr != nil {
+			continue
+		}
+		for _, svc := range c.serviceSubnets() {
+			if svcNet, sErr := netip.ParsePrefix(svc); sErr == nil && podNet.Overlaps(svcNet) {
+				err = errors.Join(err, fmt.Errorf("pod subnet %s overlaps service subnet %s", pod, svc))
+			}
+		}
+	}
+
+	return err
+}
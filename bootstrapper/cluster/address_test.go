@@ -0,0 +1,87 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressValidation(t *testing.T) {
+	node := func(hostname, address, subnet string) cluster.NodeConfig {
+		return cluster.NodeConfig{
+			HostName:     hostname,
+			Address:      address,
+			StorageType:  cluster.StorageTypeMMC,
+			EphemeralGB:  50,
+			PersistentGB: 150,
+			Subnet:       subnet,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		endpoint string
+		cps      []cluster.NodeConfig
+		workers  []cluster.NodeConfig
+		errors   []string
+	}{
+		{
+			name:     "dual stack nodes",
+			endpoint: "k8s.lan",
+			cps:      []cluster.NodeConfig{node("cp1", "192.168.50.11", "192.168.50.0/24")},
+			workers:  []cluster.NodeConfig{node("worker1", "fd00:50::14", "fd00:50::/64")},
+		},
+		{
+			name:     "duplicate address names both nodes",
+			endpoint: "192.168.50.11",
+			cps:      []cluster.NodeConfig{node("cp1", "192.168.50.11", "")},
+			workers:  []cluster.NodeConfig{node("worker1", "192.168.50.11", "")},
+			errors:   []string{"duplicate node address 192.168.50.11: cp1 and worker1"},
+		},
+		{
+			name:     "endpoint with port",
+			endpoint: "k8s.lan:6443",
+			cps:      []cluster.NodeConfig{node("cp1", "192.168.50.11", "")},
+			errors:   []string{"control plane endpoint:"},
+		},
+		{
+			name:     "address outside its subnet",
+			endpoint: "192.168.50.11",
+			cps:      []cluster.NodeConfig{node("cp1", "192.168.50.11", "192.168.60.0/24")},
+			errors:   []string{"node cp1: node address 192.168.50.11 is outside subnet 192.168.60.0/24"},
+		},
+		{
+			name:     "node subnet overlaps service subnet",
+			endpoint: "10.96.0.11",
+			cps:      []cluster.NodeConfig{node("cp1", "10.96.0.11", "10.96.0.0/24")},
+			errors: []string{
+				"node cp1: subnet 10.96.0.0/24 overlaps service subnet 10.96.0.0/12",
+				"node cp1: address 10.96.0.11 is inside service subnet 10.96.0.0/12",
+			},
+		},
+		{
+			name:     "node address inside pod subnet",
+			endpoint: "192.168.50.11",
+			cps:      []cluster.NodeConfig{node("cp1", "192.168.50.11", "")},
+			workers:  []cluster.NodeConfig{node("robin", "10.244.1.5", "")},
+			errors:   []string{"node robin: address 10.244.1.5 is inside pod subnet 10.244.0.0/16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cluster.NewConfig("test-cluster", tt.endpoint, testSecrets(), tt.cps, tt.workers)
+			if len(tt.errors) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, msg := range tt.errors {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
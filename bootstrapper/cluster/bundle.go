@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/siderolabs/crypto/x509"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+)
+
+// SecretsFromBundle converts a talosctl secrets bundle into cluster secrets.
+// The bundle carries neither an admin client certificate nor the Cilium PKI,
+// so both are generated here following policy.
+func SecretsFromBundle(bundle *secrets.Bundle, policy CertPolicy) (Secrets, error) {
+	if err := bundle.Validate(); err != nil {
+		return Secrets{}, fmt.Errorf("invalid secrets bundle: %w", err)
+	}
+
+	if bundle.Clock == nil {
+		bundle.Clock = secrets.NewClock()
+	}
+
+	ciliumCACert, ciliumCAKey, hubbleTLSCert, hubbleTLSKey, err := GenerateCiliumSecrets(policy)
+	if err != nil {
+		return Secrets{}, fmt.Errorf("failed to generate cilium secrets: %w", err)
+	}
+
+	cs := Secrets{
+		Token:                     bundle.TrustdInfo.Token,
+		OSCert:                    string(bundle.Certs.OS.Crt),
+		OSKey:                     string(bundle.Certs.OS.Key),
+		ClusterID:                 bundle.Cluster.ID,
+		ClusterSecret:             bundle.Cluster.Secret,
+		TrustdToken:               bundle.TrustdInfo.Token,
+		BootstrapToken:            bundle.Secrets.BootstrapToken,
+		SecretBoxEncryptionSecret: bundle.Secrets.SecretboxEncryptionSecret,
+		K8SCert:                   string(bundle.Certs.K8s.Crt),
+		K8SKey:                    string(bundle.Certs.K8s.Key),
+		K8SAggregatorCert:         string(bundle.Certs.K8sAggregator.Crt),
+		K8SAggregatorKey:          string(bundle.Certs.K8sAggregator.Key),
+		K8SServiceAccount:         string(bundle.Certs.K8sServiceAccount.Key),
+		ECTDCert:                  string(bundle.Certs.Etcd.Crt),
+		ECTDKey:                   string(bundle.Certs.Etcd.Key),
+		CiliumCACert:              ciliumCACert,
+		CiliumCAKey:               ciliumCAKey,
+		HubbleTLSCert:             hubbleTLSCert,
+		HubbleTLSKey:              hubbleTLSKey,
+	}
+	if err := cs.RenewAdminCert(policy.TalosClient); err != nil {
+		return Secrets{}, fmt.Errorf("failed to generate admin certificate: %w", err)
+	}
+
+	return cs, nil
+}
+
+// Bundle converts cluster secrets back into a talosctl secrets bundle. The
+// admin client certificate, Cilium and homelab PKI and any CA rotation
+// state have no place in the bundle and are dropped.
+func (cs Secrets) Bundle() (*secrets.Bundle, error) {
+	bundle := &secrets.Bundle{
+		Clock: secrets.NewClock(),
+		Cluster: &secrets.Cluster{
+			ID:     cs.ClusterID,
+			Secret: cs.ClusterSecret,
+		},
+		Secrets: &secrets.Secrets{
+			BootstrapToken:            cs.BootstrapToken,
+			SecretboxEncryptionSecret: cs.SecretBoxEncryptionSecret,
+		},
+		TrustdInfo: &secrets.TrustdInfo{
+			Token: cs.TrustdToken,
+		},
+		Certs: &secrets.Certs{
+			Etcd: &x509.PEMEncodedCertificateAndKey{
+				Crt: []byte(cs.ECTDCert),
+				Key: []byte(cs.ECTDKey),
+			},
+			K8s: &x509.PEMEncodedCertificateAndKey{
+				Crt: []byte(cs.K8SCert),
+				Key: []byte(cs.K8SKey),
+			},
+			K8sAggregator: &x509.PEMEncodedCertificateAndKey{
+				Crt: []byte(cs.K8SAggregatorCert),
+				Key: []byte(cs.K8SAggregatorKey),
+			},
+			K8sServiceAccount: &x509.PEMEncodedKey{
+				Key: []byte(cs.K8SServiceAccount),
+			},
+			OS: &x509.PEMEncodedCertificateAndKey{
+				Crt: []byte(cs.OSCert),
+				Key: []byte(cs.OSKey),
+			},
+		},
+	}
+
+	if err := bundle.Validate(); err != nil {
+		return nil, fmt.Errorf("secrets do not form a valid bundle: %w", err)
+	}
+
+	return bundle, nil
+}
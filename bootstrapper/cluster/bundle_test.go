@@ -0,0 +1,58 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSecretsFromBundle(t *testing.T) {
+	version, err := config.ParseContractFromVersion("v1.6.2")
+	require.NoError(t, err)
+
+	bundle, err := secrets.NewBundle(secrets.NewClock(), version)
+	require.NoError(t, err)
+
+	cs, err := cluster.SecretsFromBundle(bundle, cluster.CertPolicy{})
+	require.NoError(t, err)
+	require.NoError(t, cs.Validate())
+
+	assert.Equal(t, bundle.Cluster.ID, cs.ClusterID)
+	assert.Equal(t, bundle.TrustdInfo.Token, cs.Token)
+	assert.Equal(t, string(bundle.Certs.OS.Crt), cs.OSCert)
+	assert.NotEmpty(t, cs.OSAdminCert)
+	assert.NotEmpty(t, cs.CiliumCACert)
+
+	t.Run("round trips through talosctl yaml", func(t *testing.T) {
+		exported, err := cs.Bundle()
+		require.NoError(t, err)
+
+		data, err := yaml.Marshal(exported)
+		require.NoError(t, err)
+
+		var loaded secrets.Bundle
+		require.NoError(t, yaml.Unmarshal(data, &loaded))
+
+		imported, err := cluster.SecretsFromBundle(&loaded, cluster.CertPolicy{})
+		require.NoError(t, err)
+
+		assert.Equal(t, cs.ClusterID, imported.ClusterID)
+		assert.Equal(t, cs.ClusterSecret, imported.ClusterSecret)
+		assert.Equal(t, cs.BootstrapToken, imported.BootstrapToken)
+		assert.Equal(t, cs.SecretBoxEncryptionSecret, imported.SecretBoxEncryptionSecret)
+		assert.Equal(t, cs.OSKey, imported.OSKey)
+		assert.Equal(t, cs.K8SCert, imported.K8SCert)
+		assert.Equal(t, cs.K8SServiceAccount, imported.K8SServiceAccount)
+		assert.Equal(t, cs.ECTDKey, imported.ECTDKey)
+	})
+
+	t.Run("rejects invalid bundle", func(t *testing.T) {
+		_, err := cluster.SecretsFromBundle(&secrets.Bundle{}, cluster.CertPolicy{})
+		require.Error(t, err)
+	})
+}
@@ -1,128 +1,375 @@
 package cluster
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
+	"net"
+	"slices"
 	"time"
 )
 
-func generateCACert(commonName string) (certPEM, keyPEM string, err error) {
-	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to generate private key: %w", err)
+// KeyAlgorithm is the type and size of a generated private key.
+type KeyAlgorithm string
+
+const (
+	KeyECDSAP256 KeyAlgorithm = "ecdsa-p256"
+	KeyECDSAP384 KeyAlgorithm = "ecdsa-p384"
+	KeyEd25519   KeyAlgorithm = "ed25519"
+	KeyRSA3072   KeyAlgorithm = "rsa-3072"
+	KeyRSA4096   KeyAlgorithm = "rsa-4096"
+)
+
+var keyAlgorithms = []KeyAlgorithm{KeyECDSAP256, KeyECDSAP384, KeyEd25519, KeyRSA3072, KeyRSA4096}
+
+// DefaultCertValidity is how long certificates issued by the bootstrapper
+// are valid unless their CertOptions say otherwise.
+const DefaultCertValidity = 3 * 365 * 24 * time.Hour
+
+// CertOptions controls a certificate the bootstrapper issues itself. The
+// zero value gives an ECDSA P-256 key valid for DefaultCertValidity with
+// only the issuer's default common name.
+type CertOptions struct {
+	KeyAlgorithm KeyAlgorithm  `yaml:"keyAlgorithm"`
+	Validity     time.Duration `yaml:"validity"`
+	Subject      CertSubject   `yaml:"subject"`
+	// MaxPathLen limits how many intermediate CAs may follow a CA; 0
+	// allows only leaf certificates. Unset means no limit.
+	MaxPathLen *int `yaml:"maxPathLen"`
+	// DNSNames and IPAddresses are added to the certificate's SANs.
+	DNSNames    []string `yaml:"dnsNames"`
+	IPAddresses []string `yaml:"ipAddresses"`
+}
+
+// CertSubject overrides the subject of an issued certificate. An empty
+// CommonName keeps the issuer's default.
+type CertSubject struct {
+	CommonName         string   `yaml:"commonName"`
+	Organization       []string `yaml:"organization"`
+	OrganizationalUnit []string `yaml:"organizationalUnit"`
+	Country            []string `yaml:"country"`
+	Province           []string `yaml:"province"`
+	Locality           []string `yaml:"locality"`
+}
+
+// CertPolicy holds the options of each certificate the bootstrapper issues
+// itself. The Talos, Kubernetes and etcd CAs follow Talos' own defaults.
+//
+// TalosClient covers the admin and role-scoped talosconfig client
+// certificates and Kubeconfig the admin kubeconfig one. Their organization
+// carries the Talos role or Kubernetes group, so it cannot be set. Talos
+// client keys default to Ed25519, as talosctl issues them, and validity to
+// DefaultTalosconfigLifetime and DefaultKubeconfigLifetime.
+type CertPolicy struct {
+	CiliumCA    CertOptions `yaml:"ciliumCA"`
+	Hubble      CertOptions `yaml:"hubble"`
+	TalosClient CertOptions `yaml:"talosClient"`
+	Kubeconfig  CertOptions `yaml:"kubeconfig"`
+}
+
+func (p CertPolicy) Validate() error {
+	return errors.Join(
+		prefixErrors("ciliumCA", p.CiliumCA.validate(true)),
+		prefixErrors("hubble", p.Hubble.validate(false)),
+		prefixErrors("talosClient", p.TalosClient.validateClient()),
+		prefixErrors("kubeconfig", p.Kubeconfig.validateClient()),
+	)
+}
+
+func (o CertOptions) validate(ca bool) error {
+	var err error
+	if o.KeyAlgorithm != "" && !slices.Contains(keyAlgorithms, o.KeyAlgorithm) {
+		err = errors.Join(err, fmt.Errorf("unsupported key algorithm %q, expected one of %v", o.KeyAlgorithm, keyAlgorithms))
+	}
+	if o.Validity < 0 {
+		err = errors.Join(err, fmt.Errorf("validity must not be negative, got %s", o.Validity))
+	}
+	if o.MaxPathLen != nil {
+		if !ca {
+			err = errors.Join(err, errors.New("maxPathLen only applies to CAs"))
+		} else if *o.MaxPathLen < 0 {
+			err = errors.Join(err, fmt.Errorf("maxPathLen must not be negative, got %d", *o.MaxPathLen))
+		}
+	}
+	for _, ip := range o.IPAddresses {
+		if net.ParseIP(ip) == nil {
+			err = errors.Join(err, fmt.Errorf("invalid IP address SAN %q", ip))
+		}
+	}
+	return err
+}
+
+func (o CertOptions) validateClient() error {
+	err := o.validate(false)
+	if len(o.Subject.Organization) > 0 {
+		err = errors.Join(err, errors.New("subject.organization is set from the client's role"))
 	}
+	return err
+}
+
+// withDefaults returns the options with algorithm and validity where they
+// set none.
+func (o CertOptions) withDefaults(algorithm KeyAlgorithm, validity time.Duration) CertOptions {
+	if o.KeyAlgorithm == "" {
+		o.KeyAlgorithm = algorithm
+	}
+	if o.Validity == 0 {
+		o.Validity = validity
+	}
+	return o
+}
 
+// template returns a certificate template with a random 128-bit serial,
+// the subject, validity and SANs from the options.
+func (o CertOptions) template(commonName string) (*x509.Certificate, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate serial number: %w", err)
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
 	}
 
-	notBefore := time.Now()
-	notAfter := notBefore.Add(365 * 24 * time.Hour * 3)
+	if o.Subject.CommonName != "" {
+		commonName = o.Subject.CommonName
+	}
+	validity := o.Validity
+	if validity == 0 {
+		validity = DefaultCertValidity
+	}
 
-	template := x509.Certificate{
+	notBefore := time.Now()
+	template := &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
-			CommonName: commonName,
+			CommonName:         commonName,
+			Organization:       o.Subject.Organization,
+			OrganizationalUnit: o.Subject.OrganizationalUnit,
+			Country:            o.Subject.Country,
+			Province:           o.Subject.Province,
+			Locality:           o.Subject.Locality,
 		},
 		NotBefore:             notBefore,
-		NotAfter:              notAfter,
-		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		NotAfter:              notBefore.Add(validity),
+		DNSNames:              o.DNSNames,
 		BasicConstraintsValid: true,
-		IsCA:                  true,
+	}
+	for _, ip := range o.IPAddresses {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	return template, nil
+}
+
+func (o CertOptions) generateKey() (crypto.Signer, error) {
+	switch o.KeyAlgorithm {
+	case "", KeyECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyEd25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	case KeyRSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case KeyRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", o.KeyAlgorithm)
+	}
+}
+
+// marshalKey PEM encodes a private key. ECDSA keys keep the SEC 1 "EC
+// PRIVATE KEY" form existing secrets use; the others are PKCS #8.
+func marshalKey(key crypto.Signer) (string, error) {
+	if k, ok := key.(*ecdsa.PrivateKey); ok {
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create certificate: %w", err)
+		return "", err
 	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
 
-	certPEMBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+func parseKey(keyPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
 
-	privBytes, err := x509.MarshalECPrivateKey(priv)
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY", "ED25519 PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// signCert creates the certificate, self-signed when parent is nil, and
+// returns it with its new key PEM encoded.
+func signCert(template, parent *x509.Certificate, key, parentKey crypto.Signer) (certPEM, keyPEM string, err error) {
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyPEM, err = marshalKey(key)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to marshal private key: %w", err)
 	}
-	keyPEMBytes := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privBytes})
 
-	return string(certPEMBytes), string(keyPEMBytes), nil
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})), keyPEM, nil
 }
 
-func generateTLSCert(caCertPEM, caKeyPEM, commonName string) (certPEM, keyPEM string, err error) {
-	caCertBlock, _ := pem.Decode([]byte(caCertPEM))
-	if caCertBlock == nil {
-		return "", "", fmt.Errorf("failed to decode CA certificate PEM")
+func generateCACert(commonName string, opts CertOptions) (certPEM, keyPEM string, err error) {
+	return issueCACert("", "", commonName, opts)
+}
+
+// generateIntermediateCACert creates a CA signed by the parent CA. It never
+// outlives its parent.
+func generateIntermediateCACert(parentCertPEM, parentKeyPEM, commonName string, opts CertOptions) (certPEM, keyPEM string, err error) {
+	return issueCACert(parentCertPEM, parentKeyPEM, commonName, opts)
+}
+
+// issueCACert creates a CA, self-signed when parentCertPEM is empty.
+func issueCACert(parentCertPEM, parentKeyPEM, commonName string, opts CertOptions) (certPEM, keyPEM string, err error) {
+	var (
+		parent    *x509.Certificate
+		parentKey crypto.Signer
+	)
+	if parentCertPEM != "" {
+		parent, parentKey, err = parseCA(parentCertPEM, parentKeyPEM)
+		if err != nil {
+			return "", "", err
+		}
 	}
-	caCert, err := x509.ParseCertificate(caCertBlock.Bytes)
+
+	priv, err := opts.generateKey()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse CA certificate: %w", err)
+		return "", "", fmt.Errorf("failed to generate private key: %w", err)
 	}
 
-	caKeyBlock, _ := pem.Decode([]byte(caKeyPEM))
-	if caKeyBlock == nil {
-		return "", "", fmt.Errorf("failed to decode CA private key PEM")
+	template, err := opts.template(commonName)
+	if err != nil {
+		return "", "", err
+	}
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	template.IsCA = true
+	if opts.MaxPathLen != nil {
+		template.MaxPathLen = *opts.MaxPathLen
+		template.MaxPathLenZero = *opts.MaxPathLen == 0
 	}
-	caKey, err := x509.ParseECPrivateKey(caKeyBlock.Bytes)
+	if parent != nil && template.NotAfter.After(parent.NotAfter) {
+		template.NotAfter = parent.NotAfter
+	}
+
+	return signCert(template, parent, priv, parentKey)
+}
+
+// generateTLSCert issues a server and client certificate.
+func generateTLSCert(caCertPEM, caKeyPEM, commonName string, opts CertOptions) (certPEM, keyPEM string, err error) {
+	return issueLeafCert(caCertPEM, caKeyPEM, commonName, nil, opts, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+}
+
+// generateClientCert issues a client certificate whose organization, which
+// Talos and Kubernetes read as roles or groups, is fixed.
+func generateClientCert(caCertPEM, caKeyPEM, commonName string, organization []string, opts CertOptions) (certPEM, keyPEM string, err error) {
+	return issueLeafCert(caCertPEM, caKeyPEM, commonName, organization, opts, x509.ExtKeyUsageClientAuth)
+}
+
+// issueLeafCert signs a certificate for usages with the CA. It never
+// outlives the CA. A non-nil organization replaces the one in opts.
+func issueLeafCert(caCertPEM, caKeyPEM, commonName string, organization []string, opts CertOptions, usages ...x509.ExtKeyUsage) (certPEM, keyPEM string, err error) {
+	caCert, caKey, err := parseCA(caCertPEM, caKeyPEM)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse CA private key: %w", err)
+		return "", "", err
 	}
 
-	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	priv, err := opts.generateKey()
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate private key: %w", err)
 	}
 
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	template, err := opts.template(commonName)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate serial number: %w", err)
+		return "", "", err
+	}
+	if organization != nil {
+		template.Subject.Organization = organization
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	if _, ok := priv.(*rsa.PrivateKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	template.ExtKeyUsage = usages
+	if template.NotAfter.After(caCert.NotAfter) {
+		template.NotAfter = caCert.NotAfter
 	}
 
-	notBefore := time.Now()
-	notAfter := notBefore.Add(365 * 24 * time.Hour * 3)
+	return signCert(template, caCert, priv, caKey)
+}
 
-	template := x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			CommonName: commonName,
-		},
-		NotBefore:             notBefore,
-		NotAfter:              notAfter,
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
-		BasicConstraintsValid: true,
+// parseCA parses the first certificate of a CA and its private key.
+func parseCA(certPEM, keyPEM string) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, _ := pem.Decode([]byte(certPEM))
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("failed to decode CA certificate PEM")
 	}
-
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, caCert, &priv.PublicKey, caKey)
+	crt, err := x509.ParseCertificate(certBlock.Bytes)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create certificate: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
 	}
 
-	certPEMBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
-
-	privBytes, err := x509.MarshalECPrivateKey(priv)
+	key, err := parseKey(keyPEM)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to marshal private key: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse CA private key: %w", err)
 	}
-	keyPEMBytes := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privBytes})
 
-	return string(certPEMBytes), string(keyPEMBytes), nil
+	return crt, key, nil
 }
 
-func GenerateCiliumSecrets() (caCert, caKey, hubbleCert, hubbleKey string, err error) {
-	caCert, caKey, err = generateCACert("Cilium CA")
+// hubbleServerName is the Hubble server certificate's name. Hubble relay
+// verifies peers against this wildcard for the default cluster name.
+const hubbleServerName = "*.default.hubble-grpc.cilium.io"
+
+// GenerateCiliumSecrets creates the Cilium CA and the Hubble server
+// certificate it signs, following the policy.
+func GenerateCiliumSecrets(policy CertPolicy) (caCert, caKey, hubbleCert, hubbleKey string, err error) {
+	caCert, caKey, err = generateCACert("Cilium CA", policy.CiliumCA)
 	if err != nil {
 		return "", "", "", "", fmt.Errorf("failed to generate Cilium CA: %w", err)
 	}
 
-	hubbleCert, hubbleKey, err = generateTLSCert(caCert, caKey, "*.default.hubble-grpc.cilium.io")
+	hubbleCert, hubbleKey, err = generateTLSCert(caCert, caKey, hubbleServerName, policy.Hubble)
 	if err != nil {
 		return "", "", "", "", fmt.Errorf("failed to generate Hubble TLS cert: %w", err)
 	}
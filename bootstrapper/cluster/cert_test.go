@@ -0,0 +1,150 @@
+package cluster_test
+
+import (
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCiliumSecrets(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		caCert, _, hubbleCert, _, err := cluster.GenerateCiliumSecrets(cluster.CertPolicy{})
+		require.NoError(t, err)
+
+		ca := parseCert(t, caCert)
+		assert.Equal(t, "Cilium CA", ca.Subject.CommonName)
+		assert.Equal(t, x509.ECDSA, ca.PublicKeyAlgorithm)
+		assert.WithinDuration(t, time.Now().Add(cluster.DefaultCertValidity), ca.NotAfter, time.Minute)
+		assert.Equal(t, -1, ca.MaxPathLen)
+
+		hubble := parseCert(t, hubbleCert)
+		assert.Equal(t, "*.default.hubble-grpc.cilium.io", hubble.Subject.CommonName)
+		assert.Equal(t, x509.KeyUsageDigitalSignature, hubble.KeyUsage)
+		assert.NoError(t, verifies(t, hubbleCert, caCert, x509.ExtKeyUsageServerAuth))
+	})
+
+	for _, tc := range []struct {
+		algorithm cluster.KeyAlgorithm
+		want      x509.PublicKeyAlgorithm
+	}{
+		{cluster.KeyECDSAP384, x509.ECDSA},
+		{cluster.KeyEd25519, x509.Ed25519},
+		{cluster.KeyRSA3072, x509.RSA},
+	} {
+		t.Run(string(tc.algorithm), func(t *testing.T) {
+			opts := cluster.CertOptions{KeyAlgorithm: tc.algorithm}
+			caCert, caKey, hubbleCert, _, err := cluster.GenerateCiliumSecrets(cluster.CertPolicy{CiliumCA: opts, Hubble: opts})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, parseCert(t, caCert).PublicKeyAlgorithm)
+			assert.Equal(t, tc.want, parseCert(t, hubbleCert).PublicKeyAlgorithm)
+			assert.NoError(t, verifies(t, hubbleCert, caCert, x509.ExtKeyUsageServerAuth))
+
+			// The CA key round-trips, so renewals can sign with it.
+			cs := cluster.Secrets{CiliumCACert: caCert, CiliumCAKey: caKey}
+			require.NoError(t, cs.RenewHubbleCert(cluster.CertOptions{}))
+			assert.NoError(t, verifies(t, cs.HubbleTLSCert, caCert, x509.ExtKeyUsageServerAuth))
+		})
+	}
+
+	t.Run("subject, validity, path length and SANs", func(t *testing.T) {
+		pathLen := 0
+		caCert, _, hubbleCert, _, err := cluster.GenerateCiliumSecrets(cluster.CertPolicy{
+			CiliumCA: cluster.CertOptions{
+				Validity:   90 * 24 * time.Hour,
+				MaxPathLen: &pathLen,
+				Subject: cluster.CertSubject{
+					CommonName:   "Homelab Cilium CA",
+					Organization: []string{"homelab"},
+					Country:      []string{"NL"},
+				},
+			},
+			Hubble: cluster.CertOptions{
+				DNSNames:    []string{"*.default.hubble-grpc.cilium.io"},
+				IPAddresses: []string{"10.0.0.1"},
+			},
+		})
+		require.NoError(t, err)
+
+		ca := parseCert(t, caCert)
+		assert.Equal(t, "CN=Homelab Cilium CA,O=homelab,C=NL", ca.Subject.String())
+		assert.WithinDuration(t, time.Now().Add(90*24*time.Hour), ca.NotAfter, time.Minute)
+		assert.Equal(t, 0, ca.MaxPathLen)
+		assert.True(t, ca.MaxPathLenZero)
+
+		hubble := parseCert(t, hubbleCert)
+		assert.Equal(t, "CN=Homelab Cilium CA,O=homelab,C=NL", hubble.Issuer.String())
+		assert.Equal(t, []string{"*.default.hubble-grpc.cilium.io"}, hubble.DNSNames)
+		assert.True(t, hubble.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")))
+	})
+}
+
+func TestLeafNeverOutlivesCA(t *testing.T) {
+	caCert, _, hubbleCert, _, err := cluster.GenerateCiliumSecrets(cluster.CertPolicy{
+		CiliumCA: cluster.CertOptions{Validity: 30 * 24 * time.Hour},
+		Hubble:   cluster.CertOptions{Validity: 10 * 365 * 24 * time.Hour},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, parseCert(t, caCert).NotAfter, parseCert(t, hubbleCert).NotAfter)
+	assert.NoError(t, verifies(t, hubbleCert, caCert, x509.ExtKeyUsageServerAuth))
+}
+
+func TestCertPolicyValidation(t *testing.T) {
+	pathLen := 1
+	negative := -1
+
+	err := cluster.CertPolicy{
+		CiliumCA: cluster.CertOptions{KeyAlgorithm: "dsa", MaxPathLen: &negative},
+		Hubble:   cluster.CertOptions{Validity: -time.Hour, MaxPathLen: &pathLen, IPAddresses: []string{"nope"}},
+		TalosClient: cluster.CertOptions{
+			Subject: cluster.CertSubject{Organization: []string{"os:admin"}},
+		},
+		Kubeconfig: cluster.CertOptions{MaxPathLen: &pathLen},
+	}.Validate()
+
+	assert.ErrorContains(t, err, `ciliumCA: unsupported key algorithm "dsa"`)
+	assert.ErrorContains(t, err, "ciliumCA: maxPathLen must not be negative")
+	assert.ErrorContains(t, err, "hubble: validity must not be negative")
+	assert.ErrorContains(t, err, "hubble: maxPathLen only applies to CAs")
+	assert.ErrorContains(t, err, `hubble: invalid IP address SAN "nope"`)
+	assert.ErrorContains(t, err, "talosClient: subject.organization is set from the client's role")
+	assert.ErrorContains(t, err, "kubeconfig: maxPathLen only applies to CAs")
+
+	assert.NoError(t, cluster.CertPolicy{}.Validate())
+}
+
+func TestSpecCertPolicy(t *testing.T) {
+	spec, err := cluster.ParseSpec([]byte(`version: v1
+secrets:
+  certs:
+    ciliumCA:
+      keyAlgorithm: rsa-4096
+      validity: 87600h
+      maxPathLen: 0
+    hubble:
+      keyAlgorithm: ed25519
+      dnsNames: [hubble.example.com]
+`))
+	require.NoError(t, err)
+
+	ca := spec.Secrets.Certs.CiliumCA
+	assert.Equal(t, cluster.KeyRSA4096, ca.KeyAlgorithm)
+	assert.Equal(t, 87600*time.Hour, ca.Validity)
+	require.NotNil(t, ca.MaxPathLen)
+	assert.Equal(t, 0, *ca.MaxPathLen)
+	assert.Equal(t, []string{"hubble.example.com"}, spec.Secrets.Certs.Hubble.DNSNames)
+
+	_, err = cluster.ParseSpec([]byte(`version: v1
+secrets:
+  certs:
+    hubble:
+      keyAlgorithm: rsa-1024
+`))
+	assert.ErrorContains(t, err, `secrets.certs: hubble: unsupported key algorithm "rsa-1024"`)
+}
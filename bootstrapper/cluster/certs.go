@@ -0,0 +1,204 @@
+package cluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+)
+
+// CertInfo describes a certificate in the cluster PKI.
+type CertInfo struct {
+	// Name says where the certificate comes from, e.g. "kubernetes CA" or
+	// "talosconfig admin@home client".
+	Name      string
+	Subject   string
+	Issuer    string
+	SANs      []string
+	KeyType   string
+	IsCA      bool
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// DaysRemaining is the number of whole days until the certificate
+// expires, negative once it has.
+func (c CertInfo) DaysRemaining(now time.Time) int {
+	return int(c.NotAfter.Sub(now).Hours() / 24)
+}
+
+// ExpiresWithin reports whether the certificate expires before now+d.
+func (c CertInfo) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return c.NotAfter.Before(now.Add(d))
+}
+
+// Certificates parses every certificate held in the secrets.
+func (cs Secrets) Certificates() ([]CertInfo, error) {
+	var (
+		err   error
+		certs []CertInfo
+	)
+
+	for _, c := range []struct{ name, pem string }{
+		{"Talos OS CA", cs.OSCert},
+		{"Talos admin client", cs.OSAdminCert},
+		{"kubernetes CA", cs.K8SCert},
+		{"kubernetes aggregator CA", cs.K8SAggregatorCert},
+		{"etcd CA", cs.ECTDCert},
+		{"cilium CA", cs.CiliumCACert},
+		{"hubble server", cs.HubbleTLSCert},
+	} {
+		if c.pem == "" {
+			continue
+		}
+		parsed, pErr := parseCertificates(c.name, []byte(c.pem))
+		err = errors.Join(err, pErr)
+		certs = append(certs, parsed...)
+	}
+
+	// During a rotation the CAs that do not issue are listed too.
+	if cs.Rotation != nil {
+		state := "pending"
+		if cs.Rotation.Phase == RotationIssue {
+			state = "retired"
+		}
+		for _, ca := range cs.Rotation.CAs() {
+			for _, crt := range cs.AcceptedCAs(ca) {
+				parsed, pErr := parseCertificates(fmt.Sprintf("%s (%s)", caLabels[ca], state), []byte(crt))
+				err = errors.Join(err, pErr)
+				certs = append(certs, parsed...)
+			}
+		}
+	}
+
+	if cs.PKI != nil {
+		parsed, pErr := parseCertificates("homelab root CA", []byte(cs.PKI.Root.Cert))
+		err = errors.Join(err, pErr)
+		certs = append(certs, parsed...)
+
+		names := make([]string, 0, len(cs.PKI.Intermediates))
+		for name := range cs.PKI.Intermediates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			parsed, pErr := parseCertificates("homelab "+name+" intermediate CA", []byte(cs.PKI.Intermediates[name].Cert))
+			err = errors.Join(err, pErr)
+			certs = append(certs, parsed...)
+		}
+	}
+
+	return certs, err
+}
+
+// TalosconfigCertificates parses the CA and client certificate of every
+// context in a talosconfig.
+func TalosconfigCertificates(data []byte) ([]CertInfo, error) {
+	cfg, err := clientconfig.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse talosconfig: %w", err)
+	}
+
+	names := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var certs []CertInfo
+	for _, name := range names {
+		ctx := cfg.Contexts[name]
+		for _, c := range []struct{ kind, b64 string }{
+			{"CA", ctx.CA},
+			{"client", ctx.Crt},
+		} {
+			if c.b64 == "" {
+				continue
+			}
+
+			pemData, dErr := base64.StdEncoding.DecodeString(c.b64)
+			if dErr != nil {
+				err = errors.Join(err, fmt.Errorf("talosconfig %s %s: %w", name, c.kind, dErr))
+				continue
+			}
+
+			parsed, pErr := parseCertificates(fmt.Sprintf("talosconfig %s %s", name, c.kind), pemData)
+			err = errors.Join(err, pErr)
+			certs = append(certs, parsed...)
+		}
+	}
+
+	return certs, err
+}
+
+// parseCertificates parses every CERTIFICATE block in data. Bundles with
+// more than one certificate, such as CAs during a rotation, get a numbered
+// name per certificate.
+func parseCertificates(name string, data []byte) ([]CertInfo, error) {
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			blocks = append(blocks, block)
+		}
+	}
+
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%s: no PEM certificate found", name)
+	}
+
+	certs := make([]CertInfo, 0, len(blocks))
+	for i, block := range blocks {
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return certs, fmt.Errorf("%s: %w", name, err)
+		}
+
+		info := CertInfo{
+			Name:      name,
+			Subject:   crt.Subject.String(),
+			Issuer:    crt.Issuer.String(),
+			KeyType:   keyType(crt.PublicKey),
+			IsCA:      crt.IsCA,
+			NotBefore: crt.NotBefore,
+			NotAfter:  crt.NotAfter,
+		}
+		if len(blocks) > 1 {
+			info.Name = fmt.Sprintf("%s #%d", name, i+1)
+		}
+
+		info.SANs = append(info.SANs, crt.DNSNames...)
+		for _, ip := range crt.IPAddresses {
+			info.SANs = append(info.SANs, ip.String())
+		}
+
+		certs = append(certs, info)
+	}
+
+	return certs, nil
+}
+
+func keyType(pub any) string {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
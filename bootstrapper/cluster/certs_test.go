@@ -0,0 +1,90 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificates(t *testing.T) {
+	cs := generatedSecrets(t)
+
+	t.Run("secrets", func(t *testing.T) {
+		certs, err := cs.Certificates()
+		require.NoError(t, err)
+
+		byName := make(map[string]cluster.CertInfo, len(certs))
+		for _, c := range certs {
+			byName[c.Name] = c
+		}
+		assert.Len(t, byName, 7)
+
+		k8s := byName["kubernetes CA"]
+		assert.True(t, k8s.IsCA)
+		assert.Equal(t, "O=kubernetes", k8s.Subject)
+		assert.Equal(t, "ECDSA P-256", k8s.KeyType)
+
+		admin := byName["Talos admin client"]
+		assert.False(t, admin.IsCA)
+		assert.Equal(t, "O=os:admin", admin.Subject)
+		assert.Equal(t, "O=talos", admin.Issuer)
+		assert.Equal(t, "Ed25519", admin.KeyType)
+
+		hubble := byName["hubble server"]
+		assert.Equal(t, "CN=*.default.hubble-grpc.cilium.io", hubble.Subject)
+		assert.Equal(t, "CN=Cilium CA", hubble.Issuer)
+	})
+
+	t.Run("talosconfig", func(t *testing.T) {
+		cp, err := cluster.NewNodeConfig("cp1", "192.168.1.100", cluster.StorageTypeNVMe, 100, 200)
+		require.NoError(t, err)
+		cfg, err := cluster.NewConfig("test-cluster", "192.168.1.100", cs, []cluster.NodeConfig{cp}, nil)
+		require.NoError(t, err)
+
+		data, err := cfg.Talosconfig()
+		require.NoError(t, err)
+
+		certs, err := cluster.TalosconfigCertificates(data)
+		require.NoError(t, err)
+		require.Len(t, certs, 2)
+		assert.Equal(t, "talosconfig test-cluster CA", certs[0].Name)
+		assert.Equal(t, "talosconfig test-cluster client", certs[1].Name)
+		assert.Equal(t, "O=os:admin", certs[1].Subject)
+	})
+
+	t.Run("bundles are numbered", func(t *testing.T) {
+		bundled := cs
+		bundled.K8SCert = cs.K8SCert + cs.ECTDCert
+
+		certs, err := bundled.Certificates()
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range certs {
+			names = append(names, c.Name)
+		}
+		assert.Contains(t, names, "kubernetes CA #1")
+		assert.Contains(t, names, "kubernetes CA #2")
+	})
+
+	t.Run("rejects data without a certificate", func(t *testing.T) {
+		broken := cs
+		broken.HubbleTLSCert = "not a certificate"
+
+		_, err := broken.Certificates()
+		assert.ErrorContains(t, err, "hubble server: no PEM certificate found")
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		now := time.Now()
+		c := cluster.CertInfo{NotAfter: now.Add(10*24*time.Hour + time.Hour)}
+
+		assert.Equal(t, 10, c.DaysRemaining(now))
+		assert.True(t, c.ExpiresWithin(now, 30*24*time.Hour))
+		assert.False(t, c.ExpiresWithin(now, 7*24*time.Hour))
+		assert.Equal(t, -1, cluster.CertInfo{NotAfter: now.Add(-25 * time.Hour)}.DaysRemaining(now))
+	})
+}
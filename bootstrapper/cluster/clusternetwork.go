@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ClusterNetwork holds the Kubernetes pod and service networks and the
+// cluster DNS domain. Empty fields fall back to the defaults. A dual-stack
+// cluster lists one IPv4 and one IPv6 CIDR for both pods and services, in
+// the same order; the first family is the primary one.
+type ClusterNetwork struct {
+	PodSubnets     []string `yaml:"podSubnets"`
+	ServiceSubnets []string `yaml:"serviceSubnets"`
+	DNSDomain      string   `yaml:"dnsDomain"`
+}
+
+// WithClusterNetwork returns a copy of the config using n for the pod and
+// service networks and DNS domain, validated against the nodes.
+func (c Config) WithClusterNetwork(n ClusterNetwork) (Config, error) {
+	c.network = n
+	return c, c.Validate()
+}
+
+func (c Config) podSubnets() []string {
+	if len(c.network.PodSubnets) > 0 {
+		return c.network.PodSubnets
+	}
+	return []string{podSubnet}
+}
+
+func (c Config) serviceSubnets() []string {
+	if len(c.network.ServiceSubnets) > 0 {
+		return c.network.ServiceSubnets
+	}
+	return []string{serviceSubnet}
+}
+
+func (c Config) dnsDomain() string {
+	if c.network.DNSDomain != "" {
+		return c.network.DNSDomain
+	}
+	return dnsDomain
+}
+
+// ipFamilies reports which address families the pod network uses.
+func (c Config) ipFamilies() (ipv4, ipv6 bool) {
+	for _, s := range c.podSubnets() {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			ipv4 = ipv4 || p.Addr().Is4()
+			ipv6 = ipv6 || p.Addr().Is6()
+		}
+	}
+	return ipv4, ipv6
+}
+
+// validateNetworkSettings checks the pod and service CIDRs and the DNS
+// domain. The size limits are the ones kube-apiserver and the node IPAM
+// controller enforce: services no larger than /12 or /108, and pod networks large
+// enough to hand every node a /24 or /64.
+func (c Config) validateNetworkSettings() error {
+	podFamilies, err := validateCIDRs("pod subnet", c.podSubnets(), func(p netip.Prefix) error {
+		if limit := familyLimit(p, 24, 64); p.Bits() > limit {
+			return fmt.Errorf("pod subnet %s is too small to give each node a /%d, use /%d or shorter", p, limit, limit)
+		}
+		return nil
+	})
+	serviceFamilies, svcErr := validateCIDRs("service subnet", c.serviceSubnets(), func(p netip.Prefix) error {
+		if limit := familyLimit(p, 12, 108); p.Bits() < limit {
+			return fmt.Errorf("service subnet %s is too large, use /%d or longer", p, limit)
+		}
+		return nil
+	})
+	err = errors.Join(err, svcErr)
+
+	if podFamilies != "" && serviceFamilies != "" && podFamilies != serviceFamilies {
+		err = errors.Join(err, fmt.Errorf("pod subnets (%s) and service subnets (%s) must use the same IP families in the same order", podFamilies, serviceFamilies))
+	}
+
+	domain := c.dnsDomain()
+	if _, aErr := netip.ParseAddr(domain); aErr == nil || strings.HasSuffix(domain, ".") || validateHost(domain) != nil {
+		err = errors.Join(err, fmt.Errorf("DNS domain %q must be a DNS name without a trailing dot", domain))
+	}
+
+	return err
+}
+
+func familyLimit(p netip.Prefix, ipv4, ipv6 int) int {
+	if p.Addr().Is6() {
+		return ipv6
+	}
+	return ipv4
+}
+
+// validateCIDRs checks a single or dual-stack list of CIDRs and returns its
+// families, e.g. "IPv4,IPv6", or "" when the list is invalid. checkSize
+// applies the per-family size limits.
+func validateCIDRs(kind string, cidrs []string, checkSize func(netip.Prefix) error) (string, error) {
+	var (
+		err      error
+		families []string
+	)
+
+	if len(cidrs) > 2 {
+		err = errors.Join(err, fmt.Errorf("at most two %ss are allowed, one per IP family", kind))
+	}
+
+	for _, cidr := range cidrs {
+		p, pErr := netip.ParsePrefix(cidr)
+		if pErr != nil {
+			err = errors.Join(err, fmt.Errorf("invalid %s %q: %w", kind, cidr, pErr))
+			continue
+		}
+		if p != p.Masked() {
+			err = errors.Join(err, fmt.Errorf("%s %s has host bits set, use %s", kind, cidr, p.Masked()))
+		}
+
+		err = errors.Join(err, checkSize(p))
+
+		family := "IPv4"
+		if p.Addr().Is6() {
+			family = "IPv6"
+		}
+
+		for _, f := range families {
+			if f == family {
+				err = errors.Join(err, fmt.Errorf("%ss must not repeat the %s family", kind, family))
+			}
+		}
+		families = append(families, family)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(families, ","), nil
+}
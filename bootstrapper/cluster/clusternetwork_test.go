@@ -0,0 +1,121 @@
+package cluster_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterNetwork(t *testing.T) {
+	t.Run("renders dual stack networks into talos and cilium", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		cfg, err := patchTestConfig(t).WithClusterNetwork(cluster.ClusterNetwork{
+			PodSubnets:     []string{"10.32.0.0/16", "fd00:10:32::/56"},
+			ServiceSubnets: []string{"10.48.0.0/12", "fd00:10:33::/108"},
+			DNSDomain:      "homelab.internal",
+		})
+		require.NoError(t, err)
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))
+		clusterCfg := docs[0]["cluster"].(map[string]any)
+		network := clusterCfg["network"].(map[string]any)
+		assert.Equal(t, "homelab.internal", network["dnsDomain"])
+		assert.Equal(t, []any{"10.32.0.0/16", "fd00:10:32::/56"}, network["podSubnets"])
+		assert.Equal(t, []any{"10.48.0.0/12", "fd00:10:33::/108"}, network["serviceSubnets"])
+
+		manifests := clusterCfg["inlineManifests"].([]any)
+		contents := manifests[0].(map[string]any)["contents"].(string)
+		assert.Contains(t, contents, `enable-ipv4: "true"`)
+		assert.Contains(t, contents, `enable-ipv6: "true"`)
+	})
+
+	t.Run("defaults match the previous hard-coded values", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		require.NoError(t, patchTestConfig(t).GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-worker1-worker.yaml"))
+		network := docs[0]["cluster"].(map[string]any)["network"].(map[string]any)
+		assert.Equal(t, "cluster.local", network["dnsDomain"])
+		assert.Equal(t, []any{"10.244.0.0/16"}, network["podSubnets"])
+		assert.Equal(t, []any{"10.96.0.0/12"}, network["serviceSubnets"])
+	})
+
+	tests := []struct {
+		name    string
+		network cluster.ClusterNetwork
+		errors  []string
+	}{
+		{
+			name:    "ipv6 only",
+			network: cluster.ClusterNetwork{PodSubnets: []string{"fd00:10:32::/56"}, ServiceSubnets: []string{"fd00:10:33::/108"}},
+		},
+		{
+			name:    "malformed cidr",
+			network: cluster.ClusterNetwork{PodSubnets: []string{"10.32.0.0"}},
+			errors:  []string{`invalid pod subnet "10.32.0.0"`},
+		},
+		{
+			name:    "host bits set",
+			network: cluster.ClusterNetwork{ServiceSubnets: []string{"10.33.0.1/16"}},
+			errors:  []string{"service subnet 10.33.0.1/16 has host bits set, use 10.33.0.0/16"},
+		},
+		{
+			name:    "too large service subnet",
+			network: cluster.ClusterNetwork{ServiceSubnets: []string{"10.0.0.0/8"}},
+			errors:  []string{"service subnet 10.0.0.0/8 is too large, use /12 or longer"},
+		},
+		{
+			name:    "pod subnet smaller than a node range",
+			network: cluster.ClusterNetwork{PodSubnets: []string{"10.32.0.0/25"}},
+			errors:  []string{"pod subnet 10.32.0.0/25 is too small to give each node a /24, use /24 or shorter"},
+		},
+		{
+			name:    "repeated family",
+			network: cluster.ClusterNetwork{PodSubnets: []string{"10.32.0.0/16", "10.34.0.0/16"}},
+			errors:  []string{"pod subnets must not repeat the IPv4 family"},
+		},
+		{
+			name: "mismatched dual stack order",
+			network: cluster.ClusterNetwork{
+				PodSubnets:     []string{"10.32.0.0/16", "fd00:10:32::/56"},
+				ServiceSubnets: []string{"fd00:10:33::/108", "10.48.0.0/12"},
+			},
+			errors: []string{"pod subnets (IPv4,IPv6) and service subnets (IPv6,IPv4) must use the same IP families in the same order"},
+		},
+		{
+			name:    "single stack services for dual stack pods",
+			network: cluster.ClusterNetwork{PodSubnets: []string{"10.32.0.0/16", "fd00:10:32::/56"}},
+			errors:  []string{"pod subnets (IPv4,IPv6) and service subnets (IPv4)"},
+		},
+		{
+			name:    "pod subnet overlaps nodes",
+			network: cluster.ClusterNetwork{PodSubnets: []string{"192.168.0.0/16"}},
+			errors:  []string{"node cp1: address 192.168.1.100 is inside pod subnet 192.168.0.0/16"},
+		},
+		{
+			name:    "invalid dns domain",
+			network: cluster.ClusterNetwork{DNSDomain: "cluster.local."},
+			errors:  []string{`DNS domain "cluster.local." must be a DNS name without a trailing dot`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := patchTestConfig(t).WithClusterNetwork(tt.network)
+			if len(tt.errors) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, want := range tt.errors {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
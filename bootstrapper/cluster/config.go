@@ -2,7 +2,10 @@ package cluster
 
 import (
 	"errors"
+	"fmt"
+	"net/netip"
 	"os"
+	"strings"
 )
 
 type Config struct {
@@ -11,6 +14,8 @@ type Config struct {
 	controlPlanes        []NodeConfig
 	workers              []NodeConfig
 	secrets              Secrets
+	patches              patchSet
+	network              ClusterNetwork
 }
 
 func NewConfig(clusterName string, controlPlaneEndpoint string, s Secrets, cp []NodeConfig, w []NodeConfig) (Config, error) {
@@ -25,6 +30,10 @@ func NewConfig(clusterName string, controlPlaneEndpoint string, s Secrets, cp []
 	return cc, cc.Validate()
 }
 
+func (c Config) ClusterName() string {
+	return c.clusterName
+}
+
 func (c Config) Validate() error {
 	var err error
 	if c.clusterName == "" {
@@ -43,78 +52,111 @@ func (c Config) Validate() error {
 		err = errors.Join(err, validateErr)
 	}
 
-	seenAddresses := make(map[string]struct{})
+	if c.controlPlaneEndpoint != "" {
+		if hostErr := validateHost(c.controlPlaneEndpoint); hostErr != nil {
+			err = errors.Join(err, fmt.Errorf("control plane endpoint: %w", hostErr))
+		}
+	}
+
+	addressOwners := make(map[string]string)
 	seenHostnames := make(map[string]struct{})
 
-	for _, cp := range c.controlPlanes {
-		if cpErr := cp.Validate(); cpErr != nil {
-			err = errors.Join(err, cpErr)
-		}
-		if _, ok := seenAddresses[cp.Address]; ok {
-			err = errors.Join(err, errors.New("duplicate node address"))
+	for _, n := range c.nodes() {
+		err = errors.Join(err, prefixErrors("node "+n.HostName, n.Validate()))
+
+		if owner, ok := addressOwners[n.Address]; ok {
+			err = errors.Join(err, fmt.Errorf("duplicate node address %s: %s and %s", n.Address, owner, n.HostName))
 		}
-		if _, ok := seenHostnames[cp.HostName]; ok {
-			err = errors.Join(err, errors.New("duplicate node hostname"))
+		if _, ok := seenHostnames[n.HostName]; ok {
+			err = errors.Join(err, fmt.Errorf("duplicate node hostname %q", n.HostName))
 		}
-		seenAddresses[cp.Address] = struct{}{}
-		seenHostnames[cp.HostName] = struct{}{}
+		addressOwners[n.Address] = n.HostName
+		seenHostnames[n.HostName] = struct{}{}
 	}
 
-	for _, worker := range c.workers {
-		if wErr := worker.Validate(); wErr != nil {
-			err = errors.Join(err, wErr)
-		}
-		if _, ok := seenAddresses[worker.Address]; ok {
-			err = errors.Join(err, errors.New("duplicate node address"))
-		}
-		if _, ok := seenHostnames[worker.HostName]; ok {
-			err = errors.Join(err, errors.New("duplicate node hostname"))
-		}
-		seenAddresses[worker.Address] = struct{}{}
-		seenHostnames[worker.HostName] = struct{}{}
+	if settingsErr := c.validateNetworkSettings(); settingsErr != nil {
+		err = errors.Join(err, settingsErr)
+	}
+
+	if netErr := c.validateClusterNetworks(); netErr != nil {
+		err = errors.Join(err, netErr)
+	}
+
+	if vipErr := c.validateVIP(); vipErr != nil {
+		err = errors.Join(err, vipErr)
+	}
+
+	if keyErr := c.validateVolumeKeys(); keyErr != nil {
+		err = errors.Join(err, keyErr)
 	}
 
 	return err
 }
 
+// RenderedFile is a generated output file. Name is relative to the output
+// directory.
+type RenderedFile struct {
+	Name string
+	Data []byte
+}
+
+// GenerateConfigs renders and validates every machine config and the
+// talosconfig before writing any of them, so a bad node never leaves a
+// partial set of files behind. Files are written 0600 and swapped into place
+// only once all of them are on disk.
 func (c Config) GenerateConfigs(folderPath string) error {
-	if err := os.MkdirAll(folderPath, 0o755); err != nil {
+	files, err := c.RenderConfigs()
+	if err != nil {
 		return err
 	}
 
+	if err := os.MkdirAll(folderPath, 0o700); err != nil {
+		return err
+	}
+
+	return WriteFiles(folderPath, files)
+}
+
+// RenderConfigs renders every machine config and the talosconfig in memory
+// without touching the output directory.
+func (c Config) RenderConfigs() ([]RenderedFile, error) {
+	var err error
+	files := make([]RenderedFile, 0, len(c.controlPlanes)+len(c.workers)+1)
+
 	for _, controlPlane := range c.controlPlanes {
-		cpPath := folderPath + "/" + c.clusterName + "-" + controlPlane.HostName + "-controlplane.yaml"
-		if err := c.generateControlPlaneYAML(cpPath, controlPlane); err != nil {
-			return err
+		data, cpErr := c.generateControlPlaneYAML(controlPlane)
+		if cpErr != nil {
+			err = errors.Join(err, fmt.Errorf("control plane %s: %w", controlPlane.HostName, cpErr))
+			continue
 		}
+		files = append(files, RenderedFile{
+			Name: c.clusterName + "-" + controlPlane.HostName + "-controlplane.yaml",
+			Data: data,
+		})
 	}
 
 	for _, worker := range c.workers {
-		workerPath := folderPath + "/" + c.clusterName + "-" + worker.HostName + "-worker.yaml"
-		if err := c.generateWorkerYAML(workerPath, worker, c.controlPlaneEndpoint); err != nil {
-			return err
+		data, wErr := c.generateWorkerYAML(worker)
+		if wErr != nil {
+			err = errors.Join(err, fmt.Errorf("worker %s: %w", worker.HostName, wErr))
+			continue
 		}
+		files = append(files, RenderedFile{
+			Name: c.clusterName + "-" + worker.HostName + "-worker.yaml",
+			Data: data,
+		})
 	}
 
-	return c.generateTalosconfig(folderPath + "/config")
-}
-
-type StorageType string
-
-const (
-	StorageTypeMMC  StorageType = "mmc"
-	StorageTypeNVMe StorageType = "nvme"
-)
+	if err != nil {
+		return nil, err
+	}
 
-func (s StorageType) InstallDisk() string {
-	switch s {
-	case StorageTypeMMC:
-		return "/dev/mmcblk0"
-	case StorageTypeNVMe:
-		return "/dev/nvme0n1"
-	default:
-		return ""
+	talosconfig, err := c.Talosconfig()
+	if err != nil {
+		return nil, err
 	}
+
+	return append(files, RenderedFile{Name: "config", Data: talosconfig}), nil
 }
 
 type NodeConfig struct {
@@ -123,6 +165,26 @@ type NodeConfig struct {
 	StorageType  StorageType
 	EphemeralGB  int
 	PersistentGB int
+	// Disks narrows the disks StorageType selects, e.g. by model or serial.
+	Disks Disks
+	// Volumes replaces the single persistent-data volume sized by
+	// PersistentGB with named user volumes.
+	Volumes []UserVolume
+	// Encryption encrypts the STATE and EPHEMERAL system volumes.
+	Encryption SystemEncryption
+
+	// Interface is the network link the node's address lives on, e.g. end0.
+	Interface string
+	// Subnet is the LAN prefix of the node's address, e.g. 192.168.50.0/24.
+	Subnet string
+	// VIP is a shared address Talos floats between control planes on
+	// Interface. Every control plane must use the same one.
+	VIP string
+
+	// Interfaces are statically configured links. Nodes without any keep
+	// using DHCP on whatever link comes up.
+	Interfaces  []NetworkInterface
+	Nameservers []string
 }
 
 func (n NodeConfig) Validate() error {
@@ -132,9 +194,28 @@ func (n NodeConfig) Validate() error {
 	}
 	if n.Address == "" {
 		err = errors.Join(err, errors.New("node address is required"))
+	} else if hostErr := validateHost(n.Address); hostErr != nil {
+		err = errors.Join(err, fmt.Errorf("node address: %w", hostErr))
+	} else if addr, addrErr := netip.ParseAddr(n.Address); addrErr == nil {
+		if subnets := n.subnets(); len(subnets) > 0 && !containsAddr(subnets, addr) {
+			err = errors.Join(err, fmt.Errorf("node address %s is outside subnet %s", n.Address, strings.Join(subnets, ", ")))
+		}
 	}
-	if n.StorageType != StorageTypeMMC && n.StorageType != StorageTypeNVMe {
-		err = errors.Join(err, errors.New("storage type must be either mmc or nvme"))
+	if !n.StorageType.valid() {
+		err = errors.Join(err, fmt.Errorf("storage type must be one of %s", storageTypeNames()))
+	}
+	err = errors.Join(err, n.Disks.Validate())
+	err = errors.Join(err, n.Encryption.Validate())
+	if len(n.Volumes) > 0 && n.PersistentGB > 0 {
+		err = errors.Join(err, errors.New("persistentGB and volumes are mutually exclusive"))
+	}
+	seenVolumes := make(map[string]struct{})
+	for _, v := range n.Volumes {
+		err = errors.Join(err, prefixErrors("volume "+v.Name, v.Validate()))
+		if _, ok := seenVolumes[v.Name]; ok {
+			err = errors.Join(err, fmt.Errorf("duplicate volume %q", v.Name))
+		}
+		seenVolumes[v.Name] = struct{}{}
 	}
 	if n.EphemeralGB < 0 {
 		err = errors.Join(err, errors.New("ephemeral volume size cannot be negative"))
@@ -142,6 +223,19 @@ func (n NodeConfig) Validate() error {
 	if n.PersistentGB < 0 {
 		err = errors.Join(err, errors.New("persistent volume size cannot be negative"))
 	}
+	if n.Subnet != "" {
+		if _, sErr := netip.ParsePrefix(n.Subnet); sErr != nil {
+			err = errors.Join(err, fmt.Errorf("invalid subnet %q: %w", n.Subnet, sErr))
+		}
+	}
+	for _, iface := range n.Interfaces {
+		err = errors.Join(err, prefixErrors("interface "+iface.label(), iface.Validate()))
+	}
+	for _, ns := range n.Nameservers {
+		if _, nsErr := netip.ParseAddr(ns); nsErr != nil {
+			err = errors.Join(err, fmt.Errorf("invalid nameserver %q: %w", ns, nsErr))
+		}
+	}
 
 	return err
 }
@@ -157,3 +251,22 @@ func NewNodeConfig(h, a string, st StorageType, e, p int) (NodeConfig, error) {
 
 	return nc, nc.Validate()
 }
+
+// prefixErrors prefixes every error joined in err, so multi-line validation
+// output says which item each line belongs to.
+func prefixErrors(prefix string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+
+	var out error
+	for _, e := range joined.Unwrap() {
+		out = errors.Join(out, prefixErrors(prefix, e))
+	}
+	return out
+}
@@ -2,6 +2,7 @@ package cluster_test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/failuretoload/bootstrapper/cluster"
@@ -65,6 +66,47 @@ func TestNewNodeConfig(t *testing.T) {
 			expectError:  true,
 			errorMsg:     "node address is required",
 		},
+		{
+			name:         "valid IPv6 address",
+			hostName:     "worker1",
+			address:      "fd00:50::14",
+			ephemeralGB:  150,
+			persistentGB: 300,
+		},
+		{
+			name:         "valid DNS name",
+			hostName:     "worker1",
+			address:      "robin.lan",
+			ephemeralGB:  150,
+			persistentGB: 300,
+		},
+		{
+			name:         "invalid - address with port",
+			hostName:     "worker1",
+			address:      "batman.local:6443",
+			ephemeralGB:  150,
+			persistentGB: 300,
+			expectError:  true,
+			errorMsg:     "must be an IP address or DNS name without port or scheme",
+		},
+		{
+			name:         "invalid - out of range IPv4",
+			hostName:     "worker1",
+			address:      "300.1.1.1",
+			ephemeralGB:  150,
+			persistentGB: 300,
+			expectError:  true,
+			errorMsg:     `"300.1.1.1" is not a valid IP address`,
+		},
+		{
+			name:         "invalid - bad DNS label",
+			hostName:     "worker1",
+			address:      "-robin.lan",
+			ephemeralGB:  150,
+			persistentGB: 300,
+			expectError:  true,
+			errorMsg:     "neither a valid IP address nor a valid DNS name",
+		},
 		{
 			name:         "invalid - negative ephemeral",
 			hostName:     "worker1",
@@ -361,6 +403,63 @@ func TestGenerateConfigs(t *testing.T) {
 		assert.Equal(t, "dGVzdC1ldGNkLWtleQ==", etcdCA["key"])
 	})
 
+	t.Run("generates worker config with volumes", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		cp, err := cluster.NewNodeConfig("cp1", "192.168.1.100", cluster.StorageTypeNVMe, 100, 200)
+		require.NoError(t, err)
+
+		worker, err := cluster.NewNodeConfig("worker1", "192.168.1.101", cluster.StorageTypeMMC, 50, 150)
+		require.NoError(t, err)
+
+		cfg, err := cluster.NewConfig("test-cluster", "192.168.1.100", validSecrets, []cluster.NodeConfig{cp}, []cluster.NodeConfig{worker})
+		require.NoError(t, err)
+
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		f, err := os.Open(tmpDir + "/test-cluster-worker1-worker.yaml")
+		require.NoError(t, err)
+		defer f.Close()
+
+		var docs []map[string]any
+		decoder := yaml.NewDecoder(f)
+		for {
+			var doc map[string]any
+			if err := decoder.Decode(&doc); err != nil {
+				break
+			}
+			docs = append(docs, doc)
+		}
+		require.Len(t, docs, 3)
+
+		machine := docs[0]["machine"].(map[string]any)
+		assert.Equal(t, "worker", machine["type"])
+		ca := machine["ca"].(map[string]any)
+		assert.Equal(t, "dGVzdC1vcy1jZXJ0", ca["crt"])
+		assert.Equal(t, "", ca["key"])
+
+		install := machine["install"].(map[string]any)
+		assert.Equal(t, "/dev/mmcblk0", install["disk"])
+
+		cluster := docs[0]["cluster"].(map[string]any)
+		controlPlane := cluster["controlPlane"].(map[string]any)
+		assert.Equal(t, "https://192.168.1.100:6443", controlPlane["endpoint"])
+		assert.NotContains(t, cluster, "inlineManifests")
+
+		assert.Equal(t, "VolumeConfig", docs[1]["kind"])
+		assert.Equal(t, "EPHEMERAL", docs[1]["name"])
+		ephemeral := docs[1]["provisioning"].(map[string]any)
+		assert.Equal(t, "50GiB", ephemeral["maxSize"])
+		assert.Equal(t, `disk.transport == "mmc"`, ephemeral["diskSelector"].(map[string]any)["match"])
+
+		assert.Equal(t, "UserVolumeConfig", docs[2]["kind"])
+		assert.Equal(t, "persistent-data", docs[2]["name"])
+		persistent := docs[2]["provisioning"].(map[string]any)
+		assert.Equal(t, "150GiB", persistent["minSize"])
+		assert.Equal(t, "150GiB", persistent["maxSize"])
+		assert.NotContains(t, persistent, "grow")
+	})
+
 	t.Run("creates folder if it does not exist", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		nestedPath := tmpDir + "/nested/folder/path"
@@ -432,3 +531,37 @@ func TestGenerateConfigs(t *testing.T) {
 		assert.Equal(t, "dGVzdC1vcy1hZG1pbi1rZXk=", clusterContext["key"])
 	})
 }
+
+func TestGenerateConfigsFileModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := patchTestConfig(t)
+
+	require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "no temp files should be left behind")
+	for _, e := range entries {
+		info, err := e.Info()
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), e.Name())
+	}
+
+	broken, err := cfg.WithPatches(cluster.Patches{
+		Nodes: map[string][]string{
+			"worker1": {`[{"op": "remove", "path": "/machine/install/disk"}]`},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "config"), []byte("previous"), 0o600))
+
+	require.Error(t, broken.GenerateConfigs(tmpDir))
+
+	previous, err := os.ReadFile(filepath.Join(tmpDir, "config"))
+	require.NoError(t, err)
+	assert.Equal(t, "previous", string(previous), "a failed render must not replace existing files")
+
+	entries, err = os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
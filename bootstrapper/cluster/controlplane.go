@@ -1,206 +1,115 @@
 package cluster
 
 import (
-	"encoding/base64"
-	"os"
-	"text/template"
-)
+	"fmt"
 
-const controlPlaneTemplate = `version: v1alpha1
-debug: false
-persist: true
-machine:
-  type: controlplane
-  token: {{.Token}}
-  ca:
-    crt: "{{.OSCert}}"
-    key: "{{.OSKey}}"
-  certSANs:
-{{.CertSANs}}  kubelet:
-    image: ghcr.io/siderolabs/kubelet:v1.34.0
-    defaultRuntimeSeccompProfileEnabled: true
-    disableManifestsDirectory: true
-  network:
-    hostname: {{.HostName}}
-  time:
-    servers:
-      - time.cloudflare.com
-  install:
-    disk: {{.InstallDisk}}
-    image: ghcr.io/failuretoload/talos-rpi5-v1.11.5-1-custom:latest
-    wipe: false
-  features:
-    rbac: true
-    stableHostname: true
-    apidCheckExtKeyUsage: true
-    diskQuotaSupport: true
-    kubePrism:
-      enabled: true
-      port: 7445
-    hostDNS:
-      enabled: true
-      forwardKubeDNSToHost: true
-  nodeLabels:
-    node.kubernetes.io/exclude-from-external-load-balancers: ""
-cluster:
-  id: {{.ClusterID}}
-  secret: {{.ClusterSecret}}
-  controlPlane:
-    endpoint: https://{{.ControlPlaneEndpoint}}:6443
-  clusterName: {{.ClusterName}}
-  network:
-    cni:
-      name: none
-    dnsDomain: cluster.local
-    podSubnets:
-      - 10.244.0.0/16
-    serviceSubnets:
-      - 10.96.0.0/12
-  token: {{.BootstrapToken}}
-  secretboxEncryptionSecret: {{.SecretBoxEncryptionSecret}}
-  ca:
-    crt: "{{.K8SCert}}"
-    key: "{{.K8SKey}}"
-  aggregatorCA:
-    crt: "{{.K8SAggregatorCert}}"
-    key: "{{.K8SAggregatorKey}}"
-  serviceAccount:
-    key: "{{.K8SServiceAccount}}"
-  apiServer:
-    image: registry.k8s.io/kube-apiserver:v1.34.0
-    certSANs:
-{{.APICertSANs}}
-    disablePodSecurityPolicy: true
-    admissionControl:
-      - name: PodSecurity
-        configuration:
-          apiVersion: pod-security.admission.config.k8s.io/v1alpha1
-          defaults:
-            audit: restricted
-            audit-version: latest
-            enforce: baseline
-            enforce-version: latest
-            warn: restricted
-            warn-version: latest
-          exemptions:
-            namespaces:
-              - kube-system
-            runtimeClasses: []
-            usernames: []
-          kind: PodSecurityConfiguration
-    auditPolicy:
-      apiVersion: audit.k8s.io/v1
-      kind: Policy
-      rules:
-        - level: Metadata
-  controllerManager:
-    image: registry.k8s.io/kube-controller-manager:v1.34.0
-  proxy:
-    disabled: true
-  scheduler:
-    image: registry.k8s.io/kube-scheduler:v1.34.0
-  discovery:
-    enabled: true
-    registries:
-      kubernetes:
-        disabled: true
-      service: {}
-  etcd:
-    ca:
-      crt: "{{.ECTDCert}}"
-      key: "{{.ECTDKey}}"
-  allowSchedulingOnControlPlanes: true
-  inlineManifests:
-    - name: cilium
-      contents: |-
-` + ciliumManifest + `
----
-apiVersion: v1alpha1
-kind: VolumeConfig
-name: EPHEMERAL
-provisioning:
-  diskSelector:
-    match: disk.transport == "{{.StorageType}}"
-  minSize: {{.Ephemeral}}GiB
-  maxSize: {{.Ephemeral}}GiB
----
-apiVersion: v1alpha1
-kind: UserVolumeConfig
-name: persistent-data
-provisioning:
-  diskSelector:
-    match: disk.transport == "{{.StorageType}}"
-  grow: true
-  minSize: {{.Persistent}}GiB
-`
+	"github.com/siderolabs/crypto/x509"
+	"github.com/siderolabs/go-pointer"
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+)
 
-func (c Config) generateControlPlaneYAML(outPath string, controlPlane NodeConfig) error {
-	tmpl, err := template.New("controlplane").Parse(controlPlaneTemplate)
+func (c Config) generateControlPlaneYAML(controlPlane NodeConfig) ([]byte, error) {
+	docs, err := c.controlPlaneDocuments(controlPlane)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	f, err := os.Create(outPath)
+	return renderDocuments(docs, c.patchesFor(machine.TypeControlPlane, controlPlane.HostName))
+}
+
+func (c Config) controlPlaneDocuments(controlPlane NodeConfig) ([]config.Document, error) {
+	machineConfig, err := c.baseMachineConfig(machine.TypeControlPlane, controlPlane)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
 
-	certSANs := c.buildCertSANs()
-	apiCertSANs := c.buildAPICertSANs()
+	machineConfig.MachineCA = certAndKey(c.secrets.OSCert, c.secrets.OSKey)
+	machineConfig.MachineAcceptedCAs = c.secrets.acceptedCACerts(CAOS)
+	machineConfig.MachineCertSANs = c.certSANs()
+	if machineConfig.MachineNodeLabels == nil {
+		machineConfig.MachineNodeLabels = map[string]string{}
+	}
+	machineConfig.MachineNodeLabels[constants.LabelExcludeFromExternalLB] = ""
 
-	data := map[string]any{
-		"Token":                     c.secrets.Token,
-		"OSCert":                    base64.StdEncoding.EncodeToString([]byte(c.secrets.OSCert)),
-		"OSKey":                     base64.StdEncoding.EncodeToString([]byte(c.secrets.OSKey)),
-		"CertSANs":                  certSANs,
-		"HostName":                  controlPlane.HostName,
-		"InstallDisk":               controlPlane.StorageType.InstallDisk(),
-		"ClusterID":                 c.secrets.ClusterID,
-		"ClusterSecret":             c.secrets.ClusterSecret,
-		"ClusterName":               c.clusterName,
-		"ControlPlaneEndpoint":      c.controlPlaneEndpoint,
-		"BootstrapToken":            c.secrets.BootstrapToken,
-		"SecretBoxEncryptionSecret": c.secrets.SecretBoxEncryptionSecret,
-		"K8SCert":                   base64.StdEncoding.EncodeToString([]byte(c.secrets.K8SCert)),
-		"K8SKey":                    base64.StdEncoding.EncodeToString([]byte(c.secrets.K8SKey)),
-		"K8SAggregatorCert":         base64.StdEncoding.EncodeToString([]byte(c.secrets.K8SAggregatorCert)),
-		"K8SAggregatorKey":          base64.StdEncoding.EncodeToString([]byte(c.secrets.K8SAggregatorKey)),
-		"K8SServiceAccount":         base64.StdEncoding.EncodeToString([]byte(c.secrets.K8SServiceAccount)),
-		"APICertSANs":               apiCertSANs,
-		"ECTDCert":                  base64.StdEncoding.EncodeToString([]byte(c.secrets.ECTDCert)),
-		"ECTDKey":                   base64.StdEncoding.EncodeToString([]byte(c.secrets.ECTDKey)),
-		"StorageType":               controlPlane.StorageType,
-		"Ephemeral":                 controlPlane.EphemeralGB,
-		"Persistent":                controlPlane.PersistentGB,
-		"CiliumCACert":              base64.StdEncoding.EncodeToString([]byte(c.secrets.CiliumCACert)),
-		"CiliumCAKey":               base64.StdEncoding.EncodeToString([]byte(c.secrets.CiliumCAKey)),
-		"HubbleTLSCert":             base64.StdEncoding.EncodeToString([]byte(c.secrets.HubbleTLSCert)),
-		"HubbleTLSKey":              base64.StdEncoding.EncodeToString([]byte(c.secrets.HubbleTLSKey)),
+	clusterConfig, err := c.baseClusterConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	return tmpl.Execute(f, data)
-}
+	manifest, err := c.ciliumManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render cilium manifest: %w", err)
+	}
 
-func (c Config) buildCertSANs() string {
-	if len(c.controlPlanes) == 0 {
-		return ""
+	clusterConfig.ClusterSecretboxEncryptionSecret = c.secrets.SecretBoxEncryptionSecret
+	clusterConfig.ClusterCA = certAndKey(c.secrets.K8SCert, c.secrets.K8SKey)
+	clusterConfig.ClusterAcceptedCAs = c.secrets.acceptedCACerts(CAKubernetes)
+	clusterConfig.ClusterAggregatorCA = certAndKey(c.secrets.K8SAggregatorCert, c.secrets.K8SAggregatorKey)
+	clusterConfig.ClusterServiceAccount = &x509.PEMEncodedKey{Key: []byte(c.secrets.K8SServiceAccount)}
+	clusterConfig.APIServerConfig = &v1alpha1.APIServerConfig{
+		ContainerImage:                 fmt.Sprintf("%s:%s", constants.KubernetesAPIServerImage, kubernetesVersion),
+		CertSANs:                       c.certSANs(),
+		DisablePodSecurityPolicyConfig: pointer.To(true),
+		AdmissionControlConfig: v1alpha1.AdmissionPluginConfigList{
+			{
+				PluginName: "PodSecurity",
+				PluginConfiguration: v1alpha1.Unstructured{
+					Object: map[string]any{
+						"apiVersion": "pod-security.admission.config.k8s.io/v1alpha1",
+						"kind":       "PodSecurityConfiguration",
+						"defaults": map[string]any{
+							"audit":           "restricted",
+							"audit-version":   "latest",
+							"enforce":         "baseline",
+							"enforce-version": "latest",
+							"warn":            "restricted",
+							"warn-version":    "latest",
+						},
+						"exemptions": map[string]any{
+							"namespaces":     []any{"kube-system"},
+							"runtimeClasses": []any{},
+							"usernames":      []any{},
+						},
+					},
+				},
+			},
+		},
+		AuditPolicyConfig: v1alpha1.APIServerDefaultAuditPolicy,
 	}
-	result := ""
-	for _, cp := range c.controlPlanes {
-		result += "    - " + cp.Address + "\n"
+	clusterConfig.ControllerManagerConfig = &v1alpha1.ControllerManagerConfig{
+		ContainerImage: fmt.Sprintf("%s:%s", constants.KubernetesControllerManagerImage, kubernetesVersion),
+	}
+	clusterConfig.SchedulerConfig = &v1alpha1.SchedulerConfig{
+		ContainerImage: fmt.Sprintf("%s:%s", constants.KubernetesSchedulerImage, kubernetesVersion),
+	}
+	clusterConfig.EtcdConfig = &v1alpha1.EtcdConfig{
+		RootCA: certAndKey(c.secrets.TrustBundle(CAEtcd), c.secrets.ECTDKey),
+	}
+	clusterConfig.AllowSchedulingOnControlPlanes = pointer.To(true)
+	clusterConfig.ClusterInlineManifests = v1alpha1.ClusterInlineManifests{
+		{
+			InlineManifestName:     "cilium",
+			InlineManifestContents: manifest,
+		},
 	}
-	return result
-}
 
-func (c Config) buildAPICertSANs() string {
-	if len(c.controlPlanes) == 0 {
-		return ""
+	volumes, err := c.volumeDocuments(controlPlane, true)
+	if err != nil {
+		return nil, err
 	}
-	result := ""
-	result += "      - " + c.controlPlaneEndpoint + "\n"
-	for _, cp := range c.controlPlanes {
-		result += "      - " + cp.Address + "\n"
+
+	docs := []config.Document{
+		&v1alpha1.Config{
+			ConfigVersion: "v1alpha1",
+			ConfigDebug:   pointer.To(false),
+			ConfigPersist: pointer.To(true),
+			MachineConfig: machineConfig,
+			ClusterConfig: clusterConfig,
+		},
 	}
-	return result
+
+	return append(docs, volumes...), nil
 }
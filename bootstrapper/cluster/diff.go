@@ -0,0 +1,237 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeOp describes how a single value differs between two configs.
+type ChangeOp string
+
+const (
+	ChangeAdded    ChangeOp = "+"
+	ChangeRemoved  ChangeOp = "-"
+	ChangeModified ChangeOp = "~"
+)
+
+// Change is one leaf value that differs between two rendered files. Old and
+// New are display values: secrets are masked and long values are replaced by
+// a short digest.
+type Change struct {
+	Op   ChangeOp
+	Path string
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, c.Old, c.New)
+	}
+}
+
+const redacted = "<redacted>"
+
+// sensitiveKeys are map keys whose values, and everything below them, are
+// never printed. Inline manifest contents carry the Cilium CA key.
+var sensitiveKeys = map[string]struct{}{
+	"key":                       {},
+	"token":                     {},
+	"secret":                    {},
+	"secretboxEncryptionSecret": {},
+	"aescbcEncryptionSecret":    {},
+	"contents":                  {},
+	"passphrase":                {},
+}
+
+// maxDisplayLen is the longest value printed verbatim. Certificates and
+// other blobs are shown as a digest so a change is still visible.
+const maxDisplayLen = 64
+
+// DiffConfigs compares two multi-document YAML files semantically. Documents
+// are matched by kind and name rather than position, maps by key and lists by
+// index. Formatting, key order and comments are ignored.
+func DiffConfigs(old, new []byte) ([]Change, error) {
+	oldDocs, err := decodeDocuments(old)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing file: %w", err)
+	}
+
+	newDocs, err := decodeDocuments(new)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rendered file: %w", err)
+	}
+
+	var changes []Change
+	ids := make([]string, 0, len(oldDocs)+len(newDocs))
+	for _, docs := range [][]document{oldDocs, newDocs} {
+		for _, d := range docs {
+			if !slices.Contains(ids, d.id) {
+				ids = append(ids, d.id)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		a, aOK := findDocument(oldDocs, id)
+		b, bOK := findDocument(newDocs, id)
+		diffValues(&changes, id, a, aOK, b, bOK, false)
+	}
+
+	return changes, nil
+}
+
+type document struct {
+	id    string
+	value any
+}
+
+func decodeDocuments(data []byte) ([]document, error) {
+	var docs []document
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		var v any
+		err := decoder.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+
+		docs = append(docs, document{id: documentID(v, i), value: v})
+	}
+}
+
+// documentID names a document for display and matching: the v1alpha1 config
+// is "v1alpha1", other documents are "Kind/name", and anything else falls
+// back to its position.
+func documentID(v any, index int) string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Sprintf("doc[%d]", index)
+	}
+
+	if kind, ok := m["kind"].(string); ok {
+		if name, ok := m["name"].(string); ok {
+			return kind + "/" + name
+		}
+		return kind
+	}
+
+	if version, ok := m["version"].(string); ok {
+		return version
+	}
+
+	if index == 0 {
+		return ""
+	}
+	return fmt.Sprintf("doc[%d]", index)
+}
+
+func findDocument(docs []document, id string) (any, bool) {
+	for _, d := range docs {
+		if d.id == id {
+			return d.value, true
+		}
+	}
+	return nil, false
+}
+
+func diffValues(changes *[]Change, path string, a any, aOK bool, b any, bOK bool, sensitive bool) {
+	am, aMap := a.(map[string]any)
+	bm, bMap := b.(map[string]any)
+	if (aMap || !aOK) && (bMap || !bOK) && (aOK || bOK) {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			av, inA := am[k]
+			bv, inB := bm[k]
+			_, secret := sensitiveKeys[k]
+			diffValues(changes, joinKey(path, k), av, inA, bv, inB, sensitive || secret)
+		}
+		return
+	}
+
+	al, aList := a.([]any)
+	bl, bList := b.([]any)
+	if (aList || !aOK) && (bList || !bOK) && (aOK || bOK) {
+		for i := range max(len(al), len(bl)) {
+			var av, bv any
+			if i < len(al) {
+				av = al[i]
+			}
+			if i < len(bl) {
+				bv = bl[i]
+			}
+			diffValues(changes, fmt.Sprintf("%s[%d]", path, i), av, i < len(al), bv, i < len(bl), sensitive)
+		}
+		return
+	}
+
+	switch {
+	case aOK && !bOK:
+		*changes = append(*changes, Change{Op: ChangeRemoved, Path: path, Old: displayValue(a, sensitive)})
+	case !aOK && bOK:
+		*changes = append(*changes, Change{Op: ChangeAdded, Path: path, New: displayValue(b, sensitive)})
+	case !reflect.DeepEqual(a, b):
+		*changes = append(*changes, Change{Op: ChangeModified, Path: path, Old: displayValue(a, sensitive), New: displayValue(b, sensitive)})
+	}
+}
+
+func joinKey(path, key string) string {
+	if strings.ContainsAny(key, "./[] ") {
+		key = fmt.Sprintf("[%q]", key)
+	} else if path != "" {
+		key = "." + key
+	}
+	return path + key
+}
+
+func displayValue(v any, sensitive bool) string {
+	if sensitive {
+		return redacted
+	}
+
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = fmt.Sprintf("%q", v)
+	case map[string]any, []any:
+		out, _ := yaml.Marshal(v)
+		s = strings.TrimSpace(string(out))
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if len(s) > maxDisplayLen || strings.Contains(s, "\n") {
+		sum := sha256.Sum256([]byte(s))
+		return fmt.Sprintf("<%d bytes, sha256:%x>", len(s), sum[:6])
+	}
+	return s
+}
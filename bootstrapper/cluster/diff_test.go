@@ -0,0 +1,78 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	t.Run("ignores formatting and key order", func(t *testing.T) {
+		changes, err := cluster.DiffConfigs(
+			[]byte("version: v1alpha1\nmachine:\n  type: worker\n  install: {disk: /dev/sda}\n"),
+			[]byte("# comment\nmachine:\n    install:\n        disk: /dev/sda\n    type: worker\nversion: v1alpha1\n"),
+		)
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("matches documents by kind and name", func(t *testing.T) {
+		old := []byte(`version: v1alpha1
+machine:
+  type: worker
+---
+kind: VolumeConfig
+name: EPHEMERAL
+provisioning:
+  maxSize: 50GiB
+---
+kind: UserVolumeConfig
+name: persistent-data
+`)
+		changes, err := cluster.DiffConfigs(old, []byte(`version: v1alpha1
+machine:
+  type: worker
+  sysctls:
+    vm.nr_hugepages: "64"
+---
+kind: UserVolumeConfig
+name: persistent-data
+---
+kind: VolumeConfig
+name: EPHEMERAL
+provisioning:
+  maxSize: 60GiB
+`))
+		require.NoError(t, err)
+		assert.Equal(t, []cluster.Change{
+			{Op: cluster.ChangeAdded, Path: `v1alpha1.machine.sysctls["vm.nr_hugepages"]`, New: `"64"`},
+			{Op: cluster.ChangeModified, Path: "VolumeConfig/EPHEMERAL.provisioning.maxSize", Old: `"50GiB"`, New: `"60GiB"`},
+		}, changes)
+	})
+
+	t.Run("masks secret values", func(t *testing.T) {
+		changes, err := cluster.DiffConfigs(
+			[]byte("version: v1alpha1\nmachine:\n  token: old-token\n  ca:\n    crt: same\n    key: old-key\n"),
+			[]byte("version: v1alpha1\nmachine:\n  token: new-token\n  ca:\n    crt: same\n    key: new-key\n"),
+		)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		for _, c := range changes {
+			assert.Equal(t, "<redacted>", c.Old)
+			assert.Equal(t, "<redacted>", c.New)
+			assert.NotContains(t, c.String(), "token-")
+			assert.NotContains(t, c.String(), "-key")
+		}
+	})
+
+	t.Run("reports list changes by index", func(t *testing.T) {
+		changes, err := cluster.DiffConfigs(
+			[]byte("servers: [a, b]\n"),
+			[]byte("servers: [a]\n"),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, []cluster.Change{{Op: cluster.ChangeRemoved, Path: "servers[1]", Old: `"b"`}}, changes)
+	})
+}
@@ -0,0 +1,193 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/types/block"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	blockres "github.com/siderolabs/talos/pkg/machinery/resources/block"
+)
+
+const (
+	EncryptionProviderNodeID = "nodeID"
+	EncryptionProviderStatic = "static"
+	EncryptionProviderKMS    = "kms"
+)
+
+// VolumeEncryption encrypts a volume with LUKS2. The key comes from one of
+// the providers:
+//   - nodeID derives it from the node's UUID, so the disk cannot be read in
+//     another machine.
+//   - static uses a passphrase generated into the cluster secrets.
+//   - kms has Talos seal a random key with the KMS server at KMSEndpoint.
+type VolumeEncryption struct {
+	Provider    string `yaml:"provider"`
+	KMSEndpoint string `yaml:"kmsEndpoint"`
+}
+
+// SystemEncryption encrypts the Talos system volumes. STATE holds the
+// machine config, EPHEMERAL holds /var including etcd and container data.
+type SystemEncryption struct {
+	State     *VolumeEncryption `yaml:"state"`
+	Ephemeral *VolumeEncryption `yaml:"ephemeral"`
+}
+
+func (e VolumeEncryption) Validate() error {
+	var err error
+	switch e.Provider {
+	case EncryptionProviderNodeID, EncryptionProviderStatic:
+		if e.KMSEndpoint != "" {
+			err = errors.Join(err, fmt.Errorf("kmsEndpoint is only used by the %s provider", EncryptionProviderKMS))
+		}
+	case EncryptionProviderKMS:
+		if u, uErr := url.Parse(e.KMSEndpoint); e.KMSEndpoint == "" || uErr != nil || u.Host == "" {
+			err = errors.Join(err, fmt.Errorf("kms provider needs a kmsEndpoint URL such as https://kms.lan:4443, got %q", e.KMSEndpoint))
+		}
+	default:
+		err = errors.Join(err, fmt.Errorf("unsupported encryption provider %q, must be %s, %s or %s",
+			e.Provider, EncryptionProviderNodeID, EncryptionProviderStatic, EncryptionProviderKMS))
+	}
+	return err
+}
+
+func (e SystemEncryption) Validate() error {
+	var err error
+	if e.State != nil {
+		err = errors.Join(err, prefixErrors("state encryption", e.State.Validate()))
+	}
+	if e.Ephemeral != nil {
+		err = errors.Join(err, prefixErrors("ephemeral encryption", e.Ephemeral.Validate()))
+	}
+	return err
+}
+
+// volumeKeyID names a node volume's static key in Secrets.VolumeKeys.
+func volumeKeyID(hostName, volume string) string {
+	return hostName + "/" + volume
+}
+
+// staticKeyIDs lists the volumes of the node that use static keys.
+func (n NodeConfig) staticKeyIDs() []string {
+	var ids []string
+	add := func(volume string, e *VolumeEncryption) {
+		if e != nil && e.Provider == EncryptionProviderStatic {
+			ids = append(ids, volumeKeyID(n.HostName, volume))
+		}
+	}
+
+	add(constants.StatePartitionLabel, n.Encryption.State)
+	add(constants.EphemeralPartitionLabel, n.Encryption.Ephemeral)
+	for _, v := range n.Volumes {
+		add(v.Name, v.Encryption)
+	}
+
+	return ids
+}
+
+// StaticKeyIDs lists every volume in the spec that needs a static key.
+func (s Spec) StaticKeyIDs() []string {
+	var ids []string
+	for _, n := range append(append([]NodeSpec{}, s.ControlPlanes...), s.Workers...) {
+		ids = append(ids, n.nodeConfig().staticKeyIDs()...)
+	}
+	return ids
+}
+
+// AddVolumeKeys generates a passphrase for each id that does not have one
+// yet and reports whether any were added. Existing keys are never replaced,
+// since that would lock the volume.
+func (cs *Secrets) AddVolumeKeys(ids []string) (bool, error) {
+	added := false
+	for _, id := range ids {
+		if _, ok := cs.VolumeKeys[id]; ok {
+			continue
+		}
+
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return added, fmt.Errorf("failed to generate volume key: %w", err)
+		}
+
+		if cs.VolumeKeys == nil {
+			cs.VolumeKeys = map[string]string{}
+		}
+		cs.VolumeKeys[id] = base64.StdEncoding.EncodeToString(buf)
+		added = true
+	}
+	return added, nil
+}
+
+// validateVolumeKeys checks that every static key the nodes use exists.
+func (c Config) validateVolumeKeys() error {
+	var missing []string
+	for _, n := range c.nodes() {
+		for _, id := range n.staticKeyIDs() {
+			if c.secrets.VolumeKeys[id] == "" {
+				missing = append(missing, id)
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return fmt.Errorf("secrets have no static volume key for %v", missing)
+}
+
+// blockEncryption renders e for a VolumeConfig or UserVolumeConfig
+// document.
+func (c Config) blockEncryption(hostName, volume string, e *VolumeEncryption) block.EncryptionSpec {
+	if e == nil {
+		return block.EncryptionSpec{}
+	}
+
+	key := block.EncryptionKey{KeySlot: 0}
+	switch e.Provider {
+	case EncryptionProviderNodeID:
+		key.KeyNodeID = &block.EncryptionKeyNodeID{}
+	case EncryptionProviderStatic:
+		key.KeyStatic = &block.EncryptionKeyStatic{KeyData: c.secrets.VolumeKeys[volumeKeyID(hostName, volume)]}
+	case EncryptionProviderKMS:
+		key.KeyKMS = &block.EncryptionKeyKMS{KMSEndpoint: e.KMSEndpoint}
+	}
+
+	return block.EncryptionSpec{
+		EncryptionProvider: blockres.EncryptionProviderLUKS2,
+		EncryptionKeys:     []block.EncryptionKey{key},
+	}
+}
+
+// systemDiskEncryption renders the STATE encryption into
+// machine.systemDiskEncryption. STATE has no volume document of its own
+// here, while EPHEMERAL is encrypted through its VolumeConfig.
+func (c Config) systemDiskEncryption(node NodeConfig) *v1alpha1.SystemDiskEncryptionConfig {
+	e := node.Encryption.State
+	if e == nil {
+		return nil
+	}
+
+	key := &v1alpha1.EncryptionKey{KeySlot: 0}
+	switch e.Provider {
+	case EncryptionProviderNodeID:
+		key.KeyNodeID = &v1alpha1.EncryptionKeyNodeID{}
+	case EncryptionProviderStatic:
+		key.KeyStatic = &v1alpha1.EncryptionKeyStatic{KeyData: c.secrets.VolumeKeys[volumeKeyID(node.HostName, constants.StatePartitionLabel)]}
+	case EncryptionProviderKMS:
+		key.KeyKMS = &v1alpha1.EncryptionKeyKMS{KMSEndpoint: e.KMSEndpoint}
+	}
+
+	return &v1alpha1.SystemDiskEncryptionConfig{
+		StatePartition: &v1alpha1.EncryptionConfig{
+			EncryptionProvider: blockres.EncryptionProviderLUKS2.String(),
+			EncryptionKeys:     []*v1alpha1.EncryptionKey{key},
+		},
+	}
+}
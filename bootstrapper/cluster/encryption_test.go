@@ -0,0 +1,112 @@
+package cluster_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption(t *testing.T) {
+	node := func() cluster.NodeConfig {
+		return cluster.NodeConfig{
+			HostName:    "cp1",
+			Address:     "192.168.1.100",
+			StorageType: cluster.StorageTypeNVMe,
+			EphemeralGB: 50,
+			Encryption: cluster.SystemEncryption{
+				State:     &cluster.VolumeEncryption{Provider: cluster.EncryptionProviderNodeID},
+				Ephemeral: &cluster.VolumeEncryption{Provider: cluster.EncryptionProviderStatic},
+			},
+			Volumes: []cluster.UserVolume{{
+				Name:       "data",
+				MinGB:      100,
+				Encryption: &cluster.VolumeEncryption{Provider: cluster.EncryptionProviderKMS, KMSEndpoint: "https://kms.lan:4443"},
+			}},
+		}
+	}
+
+	t.Run("renders system and volume encryption", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		secrets := testSecrets()
+		added, err := secrets.AddVolumeKeys([]string{"cp1/EPHEMERAL"})
+		require.NoError(t, err)
+		assert.True(t, added)
+
+		cfg, err := cluster.NewConfig("test-cluster", "192.168.1.100", secrets, []cluster.NodeConfig{node()}, nil)
+		require.NoError(t, err)
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))
+		require.Len(t, docs, 3)
+
+		state := docs[0]["machine"].(map[string]any)["systemDiskEncryption"].(map[string]any)["state"].(map[string]any)
+		assert.Equal(t, "luks2", state["provider"])
+		assert.Equal(t, []any{map[string]any{"slot": 0, "nodeID": map[string]any{}}}, state["keys"])
+
+		ephemeral := docs[1]["encryption"].(map[string]any)
+		assert.Equal(t, []any{map[string]any{
+			"slot":   0,
+			"static": map[string]any{"passphrase": secrets.VolumeKeys["cp1/EPHEMERAL"]},
+		}}, ephemeral["keys"])
+
+		data := docs[2]["encryption"].(map[string]any)
+		assert.Equal(t, []any{map[string]any{
+			"slot": 0,
+			"kms":  map[string]any{"endpoint": "https://kms.lan:4443"},
+		}}, data["keys"])
+	})
+
+	t.Run("requires static keys in the secrets", func(t *testing.T) {
+		_, err := cluster.NewConfig("test-cluster", "192.168.1.100", testSecrets(), []cluster.NodeConfig{node()}, nil)
+		assert.ErrorContains(t, err, "secrets have no static volume key for [cp1/EPHEMERAL]")
+	})
+
+	t.Run("keeps existing keys", func(t *testing.T) {
+		secrets := cluster.Secrets{VolumeKeys: map[string]string{"cp1/STATE": "existing"}}
+		added, err := secrets.AddVolumeKeys([]string{"cp1/STATE", "cp1/data"})
+		require.NoError(t, err)
+		assert.True(t, added)
+		assert.Equal(t, "existing", secrets.VolumeKeys["cp1/STATE"])
+		assert.Len(t, secrets.VolumeKeys["cp1/data"], 44)
+
+		added, err = secrets.AddVolumeKeys([]string{"cp1/STATE", "cp1/data"})
+		require.NoError(t, err)
+		assert.False(t, added)
+	})
+
+	t.Run("lists static keys from the spec", func(t *testing.T) {
+		spec, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+controlPlanes:
+  - hostname: cp1
+    address: 192.168.1.100
+    storageType: nvme
+    encryption:
+      state: {provider: static}
+      ephemeral: {provider: nodeID}
+    volumes:
+      - name: data
+        minGB: 100
+        encryption: {provider: static}
+`))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cp1/STATE", "cp1/data"}, spec.StaticKeyIDs())
+	})
+
+	t.Run("validates providers", func(t *testing.T) {
+		n := node()
+		n.Encryption.State = &cluster.VolumeEncryption{Provider: cluster.EncryptionProviderKMS}
+		n.Encryption.Ephemeral = &cluster.VolumeEncryption{Provider: cluster.EncryptionProviderNodeID, KMSEndpoint: "https://kms.lan"}
+		n.Volumes[0].Encryption = &cluster.VolumeEncryption{Provider: "tpm"}
+
+		err := n.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `state encryption: kms provider needs a kmsEndpoint URL`)
+		assert.Contains(t, err.Error(), "ephemeral encryption: kmsEndpoint is only used by the kms provider")
+		assert.Contains(t, err.Error(), `volume data: encryption: unsupported encryption provider "tpm"`)
+	})
+}
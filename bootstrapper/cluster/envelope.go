@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	AgeKeyEnv     = "BOOTSTRAPPER_AGE_KEY"
+	AgeKeyFileEnv = "BOOTSTRAPPER_AGE_KEY_FILE"
+)
+
+// EncodeSecrets serializes secrets as JSON. When recipients are given the
+// JSON is wrapped in an ASCII-armored age envelope so it is safe to commit.
+func EncodeSecrets(cs Secrets, recipients []string) ([]byte, error) {
+	data, err := json.MarshalIndent(cs, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+
+	if len(recipients) == 0 {
+		return data, nil
+	}
+
+	parsed := make([]age.Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", r, err)
+		}
+		parsed = append(parsed, recipient)
+	}
+
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, parsed...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeSecrets parses secrets written by EncodeSecrets. Plain JSON is read
+// as-is; age envelopes are decrypted with the given identities.
+func DecodeSecrets(data []byte, identities ...age.Identity) (Secrets, error) {
+	var cs Secrets
+
+	if IsEncrypted(data) {
+		if len(identities) == 0 {
+			return cs, fmt.Errorf("secrets are age encrypted but no identity was found, set %s or %s", AgeKeyEnv, AgeKeyFileEnv)
+		}
+
+		var src io.Reader = bytes.NewReader(data)
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+			src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+		}
+
+		r, err := age.Decrypt(src, identities...)
+		if err != nil {
+			return cs, fmt.Errorf("failed to decrypt secrets: %w", err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return cs, fmt.Errorf("failed to decrypt secrets: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return cs, fmt.Errorf("failed to parse secrets: %w", err)
+	}
+
+	return cs, nil
+}
+
+func IsEncrypted(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return bytes.HasPrefix(trimmed, []byte(armor.Header)) || bytes.HasPrefix(trimmed, []byte("age-encryption.org/"))
+}
+
+// LoadAgeIdentities collects age identities from the environment. The
+// bootstrapper variables are checked first, then the SOPS conventions, so a
+// key already set up for SOPS works without extra configuration.
+func LoadAgeIdentities() ([]age.Identity, error) {
+	var identities []age.Identity
+
+	for _, env := range []string{AgeKeyEnv, "SOPS_AGE_KEY"} {
+		if v := os.Getenv(env); v != "" {
+			ids, err := age.ParseIdentities(strings.NewReader(v))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", env, err)
+			}
+			identities = append(identities, ids...)
+		}
+	}
+
+	files := []string{os.Getenv(AgeKeyFileEnv), os.Getenv("SOPS_AGE_KEY_FILE")}
+	if home, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(home, "sops", "age", "keys.txt"))
+	}
+
+	for _, path := range files {
+		if path == "" {
+			continue
+		}
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		identities = append(identities, ids...)
+	}
+
+	return identities, nil
+}
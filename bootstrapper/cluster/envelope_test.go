@@ -0,0 +1,76 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"filippo.io/age"
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeSecrets(t *testing.T) {
+	secrets := cluster.Secrets{
+		Token:  "test-token",
+		OSCert: "test-os-cert",
+		OSKey:  "test-os-key",
+	}
+
+	t.Run("plaintext round trip", func(t *testing.T) {
+		data, err := cluster.EncodeSecrets(secrets, nil)
+		require.NoError(t, err)
+		assert.False(t, cluster.IsEncrypted(data))
+		assert.Contains(t, string(data), "test-os-key")
+
+		decoded, err := cluster.DecodeSecrets(data)
+		require.NoError(t, err)
+		assert.Equal(t, secrets, decoded)
+	})
+
+	t.Run("age round trip", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+
+		data, err := cluster.EncodeSecrets(secrets, []string{identity.Recipient().String()})
+		require.NoError(t, err)
+		assert.True(t, cluster.IsEncrypted(data))
+		assert.NotContains(t, string(data), "test-os-key")
+
+		decoded, err := cluster.DecodeSecrets(data, identity)
+		require.NoError(t, err)
+		assert.Equal(t, secrets, decoded)
+	})
+
+	t.Run("encrypted without identity", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+
+		data, err := cluster.EncodeSecrets(secrets, []string{identity.Recipient().String()})
+		require.NoError(t, err)
+
+		_, err = cluster.DecodeSecrets(data)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no identity")
+	})
+
+	t.Run("identity from environment", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		t.Setenv(cluster.AgeKeyEnv, identity.String())
+
+		data, err := cluster.EncodeSecrets(secrets, []string{identity.Recipient().String()})
+		require.NoError(t, err)
+
+		identities, err := cluster.LoadAgeIdentities()
+		require.NoError(t, err)
+
+		decoded, err := cluster.DecodeSecrets(data, identities...)
+		require.NoError(t, err)
+		assert.Equal(t, secrets, decoded)
+	})
+
+	t.Run("rejects invalid recipient", func(t *testing.T) {
+		_, err := cluster.EncodeSecrets(secrets, []string{"not-a-recipient"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultKubeconfigLifetime matches the admin kubeconfig talosctl issues.
+const DefaultKubeconfigLifetime = constants.KubernetesAdminCertDefaultLifetime
+
+type kubeconfig struct {
+	APIVersion     string            `yaml:"apiVersion"`
+	Kind           string            `yaml:"kind"`
+	Clusters       []kubeconfigEntry `yaml:"clusters"`
+	Contexts       []kubeconfigEntry `yaml:"contexts"`
+	Users          []kubeconfigEntry `yaml:"users"`
+	CurrentContext string            `yaml:"current-context"`
+	Preferences    map[string]any    `yaml:"preferences"`
+	Extensions     []any             `yaml:"extensions,omitempty"`
+}
+
+type kubeconfigEntry struct {
+	Name    string         `yaml:"name"`
+	Cluster map[string]any `yaml:"cluster,omitempty"`
+	Context map[string]any `yaml:"context,omitempty"`
+	User    map[string]any `yaml:"user,omitempty"`
+}
+
+// GenerateKubeconfig writes an admin kubeconfig to outputPath.
+func (c Config) GenerateKubeconfig(outputPath string, opts CertOptions) error {
+	data, err := c.Kubeconfig(opts)
+	if err != nil {
+		return err
+	}
+
+	return WriteFile(outputPath, data)
+}
+
+// Kubeconfig returns an admin kubeconfig for the cluster. The client
+// certificate is signed offline by the Kubernetes CA with the
+// system:masters organization and follows opts; unless they say otherwise
+// it expires after DefaultKubeconfigLifetime.
+func (c Config) Kubeconfig(opts CertOptions) ([]byte, error) {
+	crt, key, err := generateClientCert(c.secrets.K8SCert, c.secrets.K8SKey,
+		constants.KubernetesAdminCertCommonName,
+		[]string{constants.KubernetesAdminCertOrganization},
+		opts.withDefaults(KeyECDSAP256, DefaultKubeconfigLifetime),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign admin client certificate: %w", err)
+	}
+
+	user := "admin@" + c.clusterName
+	cfg := kubeconfig{
+		APIVersion: "v1",
+		Kind:       "Config",
+		Clusters: []kubeconfigEntry{{
+			Name: c.clusterName,
+			Cluster: map[string]any{
+				"server":                     "https://" + net.JoinHostPort(c.controlPlaneEndpoint, apiServerPort),
+				"certificate-authority-data": base64.StdEncoding.EncodeToString([]byte(c.secrets.TrustBundle(CAKubernetes))),
+			},
+		}},
+		Contexts: []kubeconfigEntry{{
+			Name: user,
+			Context: map[string]any{
+				"cluster":   c.clusterName,
+				"namespace": "default",
+				"user":      user,
+			},
+		}},
+		Users: []kubeconfigEntry{{
+			Name: user,
+			User: map[string]any{
+				"client-certificate-data": base64.StdEncoding.EncodeToString([]byte(crt)),
+				"client-key-data":         base64.StdEncoding.EncodeToString([]byte(key)),
+			},
+		}},
+		CurrentContext: user,
+		Preferences:    map[string]any{},
+	}
+
+	return yaml.Marshal(cfg)
+}
+
+// MergeKubeconfig adds the cluster, context and user from generated to
+// existing, replacing entries with the same names, and switches the current
+// context to the generated one. Other entries are kept as they are.
+func MergeKubeconfig(existing, generated []byte) ([]byte, error) {
+	var base, add kubeconfig
+	if err := yaml.Unmarshal(existing, &base); err != nil {
+		return nil, fmt.Errorf("failed to parse existing kubeconfig: %w", err)
+	}
+	if err := yaml.Unmarshal(generated, &add); err != nil {
+		return nil, fmt.Errorf("failed to parse generated kubeconfig: %w", err)
+	}
+
+	if base.APIVersion == "" {
+		base.APIVersion = add.APIVersion
+	}
+	if base.Kind == "" {
+		base.Kind = add.Kind
+	}
+	if base.Preferences == nil {
+		base.Preferences = map[string]any{}
+	}
+
+	base.Clusters = mergeEntries(base.Clusters, add.Clusters)
+	base.Contexts = mergeEntries(base.Contexts, add.Contexts)
+	base.Users = mergeEntries(base.Users, add.Users)
+	base.CurrentContext = add.CurrentContext
+
+	return yaml.Marshal(base)
+}
+
+func mergeEntries(base, add []kubeconfigEntry) []kubeconfigEntry {
+	for _, a := range add {
+		replaced := false
+		for i := range base {
+			if base[i].Name == a.Name {
+				base[i] = a
+				replaced = true
+			}
+		}
+		if !replaced {
+			base = append(base, a)
+		}
+	}
+	return base
+}
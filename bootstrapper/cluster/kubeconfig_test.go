@@ -0,0 +1,148 @@
+package cluster_test
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func generatedSecrets(t *testing.T) cluster.Secrets {
+	t.Helper()
+
+	version, err := config.ParseContractFromVersion("v1.6.2")
+	require.NoError(t, err)
+
+	bundle, err := secrets.NewBundle(secrets.NewClock(), version)
+	require.NoError(t, err)
+
+	cs, err := cluster.SecretsFromBundle(bundle, cluster.CertPolicy{})
+	require.NoError(t, err)
+
+	return cs
+}
+
+func TestKubeconfig(t *testing.T) {
+	cs := generatedSecrets(t)
+
+	cp, err := cluster.NewNodeConfig("cp1", "192.168.1.100", cluster.StorageTypeNVMe, 100, 200)
+	require.NoError(t, err)
+
+	cfg, err := cluster.NewConfig("test-cluster", "192.168.1.10", cs, []cluster.NodeConfig{cp}, nil)
+	require.NoError(t, err)
+
+	data, err := cfg.Kubeconfig(cluster.CertOptions{Validity: 24 * time.Hour})
+	require.NoError(t, err)
+
+	var kc struct {
+		Clusters []struct {
+			Name    string            `yaml:"name"`
+			Cluster map[string]string `yaml:"cluster"`
+		} `yaml:"clusters"`
+		Users []struct {
+			Name string            `yaml:"name"`
+			User map[string]string `yaml:"user"`
+		} `yaml:"users"`
+		CurrentContext string `yaml:"current-context"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &kc))
+
+	require.Len(t, kc.Clusters, 1)
+	assert.Equal(t, "test-cluster", kc.Clusters[0].Name)
+	assert.Equal(t, "https://192.168.1.10:6443", kc.Clusters[0].Cluster["server"])
+	assert.Equal(t, "admin@test-cluster", kc.CurrentContext)
+
+	require.Len(t, kc.Users, 1)
+	crtPEM, err := base64.StdEncoding.DecodeString(kc.Users[0].User["client-certificate-data"])
+	require.NoError(t, err)
+	block, _ := pem.Decode(crtPEM)
+	require.NotNil(t, block)
+	crt, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"system:masters"}, crt.Subject.Organization)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, crt.ExtKeyUsage)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), crt.NotAfter, time.Minute)
+
+	caBlock, _ := pem.Decode([]byte(cs.K8SCert))
+	require.NotNil(t, caBlock)
+	ca, err := x509.ParseCertificate(caBlock.Bytes)
+	require.NoError(t, err)
+	require.NoError(t, crt.CheckSignatureFrom(ca))
+
+	t.Run("client certificate follows options", func(t *testing.T) {
+		data, err := cfg.Kubeconfig(cluster.CertOptions{KeyAlgorithm: cluster.KeyRSA3072, Subject: cluster.CertSubject{CommonName: "homelab-admin"}})
+		require.NoError(t, err)
+		require.NoError(t, yaml.Unmarshal(data, &kc))
+
+		crtPEM, err := base64.StdEncoding.DecodeString(kc.Users[0].User["client-certificate-data"])
+		require.NoError(t, err)
+		crt := parseCert(t, string(crtPEM))
+		assert.Equal(t, x509.RSA, crt.PublicKeyAlgorithm)
+		assert.Equal(t, "homelab-admin", crt.Subject.CommonName)
+		assert.Equal(t, []string{"system:masters"}, crt.Subject.Organization)
+		assert.WithinDuration(t, time.Now().Add(cluster.DefaultKubeconfigLifetime), crt.NotAfter, time.Minute)
+	})
+
+	t.Run("merges into an existing kubeconfig", func(t *testing.T) {
+		existing := []byte(`apiVersion: v1
+kind: Config
+clusters:
+  - name: other
+    cluster:
+      server: https://other:6443
+  - name: test-cluster
+    cluster:
+      server: https://stale:6443
+contexts:
+  - name: other
+    context:
+      cluster: other
+      user: other
+users:
+  - name: other
+    user:
+      token: abc
+current-context: other
+`)
+		merged, err := cluster.MergeKubeconfig(existing, data)
+		require.NoError(t, err)
+
+		var out struct {
+			Clusters []struct {
+				Name    string            `yaml:"name"`
+				Cluster map[string]string `yaml:"cluster"`
+			} `yaml:"clusters"`
+			Contexts []struct {
+				Name string `yaml:"name"`
+			} `yaml:"contexts"`
+			Users []struct {
+				Name string `yaml:"name"`
+			} `yaml:"users"`
+			CurrentContext string `yaml:"current-context"`
+		}
+		require.NoError(t, yaml.Unmarshal(merged, &out))
+
+		require.Len(t, out.Clusters, 2)
+		assert.Equal(t, "other", out.Clusters[0].Name)
+		assert.Equal(t, "https://192.168.1.10:6443", out.Clusters[1].Cluster["server"])
+		assert.Len(t, out.Contexts, 2)
+		assert.Len(t, out.Users, 2)
+		assert.Equal(t, "admin@test-cluster", out.CurrentContext)
+	})
+
+	t.Run("merges into an empty file", func(t *testing.T) {
+		merged, err := cluster.MergeKubeconfig(nil, data)
+		require.NoError(t, err)
+		assert.Contains(t, string(merged), "apiVersion: v1")
+		assert.Contains(t, string(merged), "current-context: admin@test-cluster")
+	})
+}
@@ -0,0 +1,214 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/siderolabs/crypto/x509"
+	"github.com/siderolabs/go-pointer"
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	"github.com/siderolabs/talos/pkg/machinery/config/container"
+	"github.com/siderolabs/talos/pkg/machinery/config/encoder"
+	"github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/block"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	"github.com/siderolabs/talos/pkg/machinery/config/validation"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+)
+
+const (
+	kubernetesVersion = "v1.34.0"
+	installImage      = "ghcr.io/failuretoload/talos-rpi5-v1.11.5-1-custom:latest"
+	timeServer        = "time.cloudflare.com"
+	podSubnet         = "10.244.0.0/16"
+	serviceSubnet     = "10.96.0.0/12"
+	dnsDomain         = "cluster.local"
+	apiServerPort     = "6443"
+	persistentVolume  = "persistent-data"
+)
+
+func encodeDocuments(docs []config.Document) ([]byte, error) {
+	c, err := container.New(docs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.EncodeBytes(encoder.WithComments(encoder.CommentsDisabled))
+}
+
+// renderDocuments applies user patches, encodes the machine config and
+// checks it the way Talos will when the node applies it.
+func renderDocuments(docs []config.Document, patches []configpatcher.Patch) ([]byte, error) {
+	docs, err := applyPatches(docs, patches)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := encodeDocuments(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateMachineConfig(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// metalMode is the validation runtime mode for nodes installed to disk.
+type metalMode struct{}
+
+func (metalMode) String() string        { return "metal" }
+func (metalMode) RequiresInstall() bool { return true }
+func (metalMode) InContainer() bool     { return false }
+
+func validateMachineConfig(data []byte) error {
+	provider, err := configloader.NewFromBytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to load rendered config: %w", err)
+	}
+
+	if _, err := provider.Validate(metalMode{}, validation.WithLocal()); err != nil {
+		return fmt.Errorf("invalid machine config: %w", err)
+	}
+
+	return nil
+}
+
+func (c Config) baseMachineConfig(machineType machine.Type, node NodeConfig) (*v1alpha1.MachineConfig, error) {
+	machineConfig := &v1alpha1.MachineConfig{
+		MachineType:  machineType.String(),
+		MachineToken: c.secrets.Token,
+		MachineKubelet: &v1alpha1.KubeletConfig{
+			KubeletImage: fmt.Sprintf("%s:%s", constants.KubeletImage, kubernetesVersion),
+			KubeletDefaultRuntimeSeccompProfileEnabled: pointer.To(true),
+			KubeletDisableManifestsDirectory:           pointer.To(true),
+		},
+		MachineNetwork: &v1alpha1.NetworkConfig{
+			NetworkHostname:   node.HostName,
+			NetworkInterfaces: node.networkDevices(),
+			NameServers:       node.Nameservers,
+		},
+		MachineTime: &v1alpha1.TimeConfig{
+			TimeServers: []string{timeServer},
+		},
+		MachineInstall: &v1alpha1.InstallConfig{
+			InstallImage: installImage,
+			InstallWipe:  pointer.To(false),
+		},
+		MachineFeatures: &v1alpha1.FeaturesConfig{
+			RBAC:                 pointer.To(true),
+			StableHostname:       pointer.To(true),
+			ApidCheckExtKeyUsage: pointer.To(true),
+			DiskQuotaSupport:     pointer.To(true),
+			KubePrismSupport: &v1alpha1.KubePrism{
+				ServerEnabled: pointer.To(true),
+				ServerPort:    constants.DefaultKubePrismPort,
+			},
+			HostDNSSupport: &v1alpha1.HostDNSConfig{
+				HostDNSEnabled:              pointer.To(true),
+				HostDNSForwardKubeDNSToHost: pointer.To(true),
+			},
+		},
+	}
+
+	if err := node.installConfig(machineConfig.MachineInstall); err != nil {
+		return nil, err
+	}
+
+	labels, annotations, err := node.longhornMetadata(machineType == machine.TypeControlPlane)
+	if err != nil {
+		return nil, err
+	}
+	machineConfig.MachineNodeLabels = labels
+	machineConfig.MachineNodeAnnotations = annotations
+	machineConfig.MachineSystemDiskEncryption = c.systemDiskEncryption(node)
+
+	if subnets := node.subnets(); len(subnets) > 0 {
+		machineConfig.MachineKubelet.KubeletNodeIP = &v1alpha1.KubeletNodeIPConfig{
+			KubeletNodeIPValidSubnets: subnets,
+		}
+	}
+
+	return machineConfig, nil
+}
+
+func (c Config) baseClusterConfig() (*v1alpha1.ClusterConfig, error) {
+	endpoint, err := url.Parse("https://" + net.JoinHostPort(c.controlPlaneEndpoint, apiServerPort))
+	if err != nil {
+		return nil, fmt.Errorf("invalid control plane endpoint: %w", err)
+	}
+
+	return &v1alpha1.ClusterConfig{
+		ClusterID:     c.secrets.ClusterID,
+		ClusterSecret: c.secrets.ClusterSecret,
+		ControlPlane: &v1alpha1.ControlPlaneConfig{
+			Endpoint: &v1alpha1.Endpoint{URL: endpoint},
+		},
+		ClusterName: c.clusterName,
+		ClusterNetwork: &v1alpha1.ClusterNetworkConfig{
+			CNI:           &v1alpha1.CNIConfig{CNIName: constants.NoneCNI},
+			DNSDomain:     c.dnsDomain(),
+			PodSubnet:     c.podSubnets(),
+			ServiceSubnet: c.serviceSubnets(),
+		},
+		BootstrapToken: c.secrets.BootstrapToken,
+		ProxyConfig: &v1alpha1.ProxyConfig{
+			Disabled: pointer.To(true),
+		},
+		ClusterDiscoveryConfig: &v1alpha1.ClusterDiscoveryConfig{
+			DiscoveryEnabled: pointer.To(true),
+			DiscoveryRegistries: v1alpha1.DiscoveryRegistriesConfig{
+				RegistryKubernetes: v1alpha1.RegistryKubernetesConfig{
+					RegistryDisabled: pointer.To(true),
+				},
+			},
+		},
+	}, nil
+}
+
+func gibibytes(n int) block.ByteSize {
+	if n <= 0 {
+		return block.ByteSize{}
+	}
+
+	return block.MustByteSize(fmt.Sprintf("%dGiB", n))
+}
+
+func certAndKey(crt, key string) *x509.PEMEncodedCertificateAndKey {
+	return &x509.PEMEncodedCertificateAndKey{
+		Crt: []byte(crt),
+		Key: []byte(key),
+	}
+}
+
+func (c Config) ciliumManifest() (string, error) {
+	tmpl, err := template.New("cilium").Parse(ciliumManifest)
+	if err != nil {
+		return "", err
+	}
+
+	ipv4, ipv6 := c.ipFamilies()
+	data := map[string]any{
+		"EnableIPv4":    ipv4,
+		"EnableIPv6":    ipv6,
+		"CiliumCACert":  base64.StdEncoding.EncodeToString([]byte(c.secrets.TrustBundle(CACilium))),
+		"CiliumCAKey":   base64.StdEncoding.EncodeToString([]byte(c.secrets.CiliumCAKey)),
+		"HubbleTLSCert": base64.StdEncoding.EncodeToString([]byte(c.secrets.HubbleTLSCert)),
+		"HubbleTLSKey":  base64.StdEncoding.EncodeToString([]byte(c.secrets.HubbleTLSKey)),
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
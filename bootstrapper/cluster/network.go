@@ -0,0 +1,269 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/siderolabs/go-pointer"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+)
+
+// NetworkInterface is a link configured on a node. It is matched by Name,
+// or by Selector when the kernel name is not stable. A link without
+// addresses uses DHCP.
+type NetworkInterface struct {
+	Name      string          `yaml:"name"`
+	Selector  *DeviceSelector `yaml:"deviceSelector"`
+	Addresses []string        `yaml:"addresses"`
+	// Gateway adds a default route for the gateway's address family.
+	Gateway string  `yaml:"gateway"`
+	Routes  []Route `yaml:"routes"`
+	MTU     int     `yaml:"mtu"`
+	VLANs   []VLAN  `yaml:"vlans"`
+	Bond    *Bond   `yaml:"bond"`
+}
+
+// DeviceSelector matches a link by hardware rather than by name.
+type DeviceSelector struct {
+	HardwareAddr string `yaml:"hardwareAddr"`
+	BusPath      string `yaml:"busPath"`
+	Driver       string `yaml:"driver"`
+}
+
+type Route struct {
+	Network string `yaml:"network"`
+	Gateway string `yaml:"gateway"`
+	Metric  uint32 `yaml:"metric"`
+}
+
+type VLAN struct {
+	ID        uint16   `yaml:"id"`
+	Addresses []string `yaml:"addresses"`
+	Gateway   string   `yaml:"gateway"`
+	Routes    []Route  `yaml:"routes"`
+	MTU       uint32   `yaml:"mtu"`
+}
+
+// Bond aggregates Interfaces into the link, e.g. mode 802.3ad.
+type Bond struct {
+	Interfaces []string `yaml:"interfaces"`
+	Mode       string   `yaml:"mode"`
+}
+
+func (i NetworkInterface) label() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	if i.Selector != nil {
+		return fmt.Sprintf("selector %+v", *i.Selector)
+	}
+	return "unnamed"
+}
+
+func (i NetworkInterface) Validate() error {
+	var err error
+	switch {
+	case i.Name == "" && i.Selector == nil:
+		err = errors.Join(err, errors.New("name or device selector is required"))
+	case i.Name != "" && i.Selector != nil:
+		err = errors.Join(err, errors.New("name and device selector are mutually exclusive"))
+	case i.Selector != nil && *i.Selector == DeviceSelector{}:
+		err = errors.Join(err, errors.New("device selector must match on at least one field"))
+	}
+
+	if i.MTU < 0 {
+		err = errors.Join(err, errors.New("MTU cannot be negative"))
+	}
+
+	err = errors.Join(err, validateAddressing(i.Addresses, i.Gateway, i.Routes))
+
+	seenVLANs := make(map[uint16]struct{})
+	for _, v := range i.VLANs {
+		if v.ID == 0 || v.ID > 4094 {
+			err = errors.Join(err, fmt.Errorf("vlan %d: ID must be between 1 and 4094", v.ID))
+		}
+		if _, ok := seenVLANs[v.ID]; ok {
+			err = errors.Join(err, fmt.Errorf("vlan %d: duplicate ID", v.ID))
+		}
+		seenVLANs[v.ID] = struct{}{}
+
+		err = errors.Join(err, prefixErrors(fmt.Sprintf("vlan %d", v.ID), validateAddressing(v.Addresses, v.Gateway, v.Routes)))
+	}
+
+	if i.Bond != nil {
+		if len(i.Bond.Interfaces) == 0 {
+			err = errors.Join(err, errors.New("bond needs at least one member interface"))
+		}
+		if i.Bond.Mode == "" {
+			err = errors.Join(err, errors.New("bond mode is required"))
+		}
+	}
+
+	return err
+}
+
+func validateAddressing(addresses []string, gateway string, routes []Route) error {
+	var err error
+	for _, a := range addresses {
+		if _, pErr := netip.ParsePrefix(a); pErr != nil {
+			err = errors.Join(err, fmt.Errorf("address %q must be in CIDR notation: %w", a, pErr))
+		}
+	}
+
+	if gateway != "" {
+		if _, gErr := netip.ParseAddr(gateway); gErr != nil {
+			err = errors.Join(err, fmt.Errorf("invalid gateway %q: %w", gateway, gErr))
+		}
+	}
+
+	for _, r := range routes {
+		if _, nErr := netip.ParsePrefix(r.Network); nErr != nil {
+			err = errors.Join(err, fmt.Errorf("route %q: invalid network: %w", r.Network, nErr))
+		}
+		if r.Gateway != "" {
+			if _, gErr := netip.ParseAddr(r.Gateway); gErr != nil {
+				err = errors.Join(err, fmt.Errorf("route %q: invalid gateway %q: %w", r.Network, r.Gateway, gErr))
+			}
+		}
+	}
+
+	return err
+}
+
+// subnets returns the node's declared subnet plus the networks of the
+// static addresses on its links, used for kubelet nodeIP selection. VLAN
+// addresses are left out so kubelet keeps advertising the primary network.
+func (n NodeConfig) subnets() []string {
+	var subnets []string
+	add := func(s string) {
+		if s != "" && !slices.Contains(subnets, s) {
+			subnets = append(subnets, s)
+		}
+	}
+
+	add(n.Subnet)
+	for _, iface := range n.Interfaces {
+		for _, a := range iface.Addresses {
+			if p, err := netip.ParsePrefix(a); err == nil {
+				add(p.Masked().String())
+			}
+		}
+	}
+
+	return subnets
+}
+
+// staticAddresses returns the host part of every static interface and
+// VLAN address.
+func (n NodeConfig) staticAddresses() []netip.Addr {
+	var addrs []netip.Addr
+	add := func(prefixes []string) {
+		for _, a := range prefixes {
+			if p, err := netip.ParsePrefix(a); err == nil {
+				addrs = append(addrs, p.Addr())
+			}
+		}
+	}
+
+	for _, iface := range n.Interfaces {
+		add(iface.Addresses)
+		for _, vlan := range iface.VLANs {
+			add(vlan.Addresses)
+		}
+	}
+
+	return addrs
+}
+
+// networkDevices renders the node's links. The VIP is attached to the link
+// named by Interface, which is added with DHCP when it is not declared.
+func (n NodeConfig) networkDevices() v1alpha1.NetworkDeviceList {
+	devices := make(v1alpha1.NetworkDeviceList, 0, len(n.Interfaces)+1)
+	for _, iface := range n.Interfaces {
+		devices = append(devices, iface.device())
+	}
+
+	if n.VIP == "" {
+		return devices
+	}
+
+	vip := &v1alpha1.DeviceVIPConfig{SharedIP: n.VIP}
+	for _, d := range devices {
+		if d.DeviceInterface == n.Interface {
+			d.DeviceVIPConfig = vip
+			return devices
+		}
+	}
+
+	return append(devices, &v1alpha1.Device{
+		DeviceInterface: n.Interface,
+		DeviceDHCP:      pointer.To(true),
+		DeviceVIPConfig: vip,
+	})
+}
+
+func (i NetworkInterface) device() *v1alpha1.Device {
+	d := &v1alpha1.Device{
+		DeviceInterface: i.Name,
+		DeviceAddresses: i.Addresses,
+		DeviceRoutes:    routes(i.Gateway, i.Routes),
+		DeviceMTU:       i.MTU,
+	}
+
+	if len(i.Addresses) == 0 {
+		d.DeviceDHCP = pointer.To(true)
+	}
+
+	if i.Selector != nil {
+		d.DeviceSelector = &v1alpha1.NetworkDeviceSelector{
+			NetworkDeviceHardwareAddress: i.Selector.HardwareAddr,
+			NetworkDeviceBus:             i.Selector.BusPath,
+			NetworkDeviceKernelDriver:    i.Selector.Driver,
+		}
+	}
+
+	if i.Bond != nil {
+		d.DeviceBond = &v1alpha1.Bond{
+			BondInterfaces: i.Bond.Interfaces,
+			BondMode:       i.Bond.Mode,
+		}
+	}
+
+	for _, v := range i.VLANs {
+		vlan := &v1alpha1.Vlan{
+			VlanID:        v.ID,
+			VlanAddresses: v.Addresses,
+			VlanRoutes:    routes(v.Gateway, v.Routes),
+			VlanMTU:       v.MTU,
+		}
+		if len(v.Addresses) == 0 {
+			vlan.VlanDHCP = pointer.To(true)
+		}
+		d.DeviceVlans = append(d.DeviceVlans, vlan)
+	}
+
+	return d
+}
+
+func routes(gateway string, extra []Route) []*v1alpha1.Route {
+	var out []*v1alpha1.Route
+	if gateway != "" {
+		network := "0.0.0.0/0"
+		if addr, err := netip.ParseAddr(gateway); err == nil && addr.Is6() {
+			network = "::/0"
+		}
+		out = append(out, &v1alpha1.Route{RouteNetwork: network, RouteGateway: gateway})
+	}
+
+	for _, r := range extra {
+		out = append(out, &v1alpha1.Route{
+			RouteNetwork: r.Network,
+			RouteGateway: r.Gateway,
+			RouteMetric:  r.Metric,
+		})
+	}
+
+	return out
+}
@@ -0,0 +1,106 @@
+package cluster_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticNetwork(t *testing.T) {
+	t.Run("renders interfaces, routes, vlans, bonds and nameservers", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		cp := vipNode("cp1", "192.168.50.11", "192.168.50.10")
+		cp.Subnet = ""
+		cp.Interfaces = []cluster.NetworkInterface{
+			{
+				Name:      "end0",
+				Addresses: []string{"192.168.50.11/24"},
+				Gateway:   "192.168.50.1",
+				MTU:       9000,
+				VLANs: []cluster.VLAN{
+					{ID: 20, Addresses: []string{"10.20.0.11/24"}},
+					{ID: 30},
+				},
+			},
+			{
+				Name: "bond0",
+				Bond: &cluster.Bond{Interfaces: []string{"eth1", "eth2"}, Mode: "802.3ad"},
+			},
+		}
+		cp.Nameservers = []string{"192.168.50.1", "2001:db8::53"}
+
+		worker := vipNode("worker1", "192.168.50.14", "")
+		worker.Interface = ""
+		worker.Interfaces = []cluster.NetworkInterface{{
+			Selector: &cluster.DeviceSelector{HardwareAddr: "2c:cf:67:*"},
+			Routes:   []cluster.Route{{Network: "10.0.0.0/8", Gateway: "192.168.50.254", Metric: 100}},
+		}}
+
+		cfg, err := cluster.NewConfig("test-cluster", "192.168.50.10", testSecrets(), []cluster.NodeConfig{cp}, []cluster.NodeConfig{worker})
+		require.NoError(t, err)
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))
+		machine := docs[0]["machine"].(map[string]any)
+		network := machine["network"].(map[string]any)
+		assert.Equal(t, []any{"192.168.50.1", "2001:db8::53"}, network["nameservers"])
+
+		interfaces := network["interfaces"].([]any)
+		require.Len(t, interfaces, 2)
+
+		end0 := interfaces[0].(map[string]any)
+		assert.Equal(t, []any{"192.168.50.11/24"}, end0["addresses"])
+		assert.Equal(t, []any{map[string]any{"network": "0.0.0.0/0", "gateway": "192.168.50.1"}}, end0["routes"])
+		assert.Equal(t, 9000, end0["mtu"])
+		assert.Equal(t, map[string]any{"ip": "192.168.50.10"}, end0["vip"])
+		assert.NotContains(t, end0, "dhcp")
+		vlans := end0["vlans"].([]any)
+		require.Len(t, vlans, 2)
+		assert.Equal(t, 20, vlans[0].(map[string]any)["vlanId"])
+		assert.Equal(t, true, vlans[1].(map[string]any)["dhcp"])
+
+		bond := interfaces[1].(map[string]any)
+		assert.Equal(t, "802.3ad", bond["bond"].(map[string]any)["mode"])
+		assert.Equal(t, true, bond["dhcp"])
+
+		nodeIP := machine["kubelet"].(map[string]any)["nodeIP"].(map[string]any)
+		assert.Equal(t, []any{"192.168.50.0/24"}, nodeIP["validSubnets"])
+
+		docs = readDocuments(t, filepath.Join(tmpDir, "test-cluster-worker1-worker.yaml"))
+		machine = docs[0]["machine"].(map[string]any)
+		iface := machine["network"].(map[string]any)["interfaces"].([]any)[0].(map[string]any)
+		assert.Equal(t, map[string]any{"hardwareAddr": "2c:cf:67:*"}, iface["deviceSelector"])
+		assert.Equal(t, []any{map[string]any{"network": "10.0.0.0/8", "gateway": "192.168.50.254", "metric": 100}}, iface["routes"])
+		nodeIP = machine["kubelet"].(map[string]any)["nodeIP"].(map[string]any)
+		assert.Equal(t, []any{"192.168.50.0/24"}, nodeIP["validSubnets"])
+	})
+
+	t.Run("omits nodeIP without a declared subnet", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		require.NoError(t, patchTestConfig(t).GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-worker1-worker.yaml"))
+		assert.NotContains(t, docs[0]["machine"].(map[string]any)["kubelet"], "nodeIP")
+	})
+
+	t.Run("reports invalid settings per interface", func(t *testing.T) {
+		node := vipNode("cp1", "192.168.50.11", "")
+		node.Interfaces = []cluster.NetworkInterface{
+			{Name: "end0", Addresses: []string{"192.168.50.11"}, VLANs: []cluster.VLAN{{ID: 5000}}},
+			{Bond: &cluster.Bond{}},
+		}
+		node.Nameservers = []string{"dns.local"}
+
+		err := node.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `interface end0: address "192.168.50.11" must be in CIDR notation`)
+		assert.Contains(t, err.Error(), "interface end0: vlan 5000: ID must be between 1 and 4094")
+		assert.Contains(t, err.Error(), "interface unnamed: name or device selector is required")
+		assert.Contains(t, err.Error(), "bond mode is required")
+		assert.Contains(t, err.Error(), `invalid nameserver "dns.local"`)
+	})
+}
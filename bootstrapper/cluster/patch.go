@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	"github.com/siderolabs/talos/pkg/machinery/config/container"
+	"github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+)
+
+// Patches are user supplied config patches in talosctl format: inline
+// strategic merge or RFC 6902 JSON patches, or "@file" references. They are
+// applied cluster-wide first, then per role, then per hostname.
+type Patches struct {
+	Cluster      []string
+	ControlPlane []string
+	Worker       []string
+	Nodes        map[string][]string
+}
+
+type patchSet struct {
+	cluster      []configpatcher.Patch
+	controlPlane []configpatcher.Patch
+	worker       []configpatcher.Patch
+	nodes        map[string][]configpatcher.Patch
+}
+
+// WithPatches returns a copy of the config that applies p on top of every
+// generated machine config.
+func (c Config) WithPatches(p Patches) (Config, error) {
+	var (
+		err  error
+		set  patchSet
+		load = func(scope string, in []string) []configpatcher.Patch {
+			loaded, loadErr := configpatcher.LoadPatches(in)
+			if loadErr != nil {
+				err = errors.Join(err, fmt.Errorf("%s patches: %w", scope, loadErr))
+			}
+			return loaded
+		}
+	)
+
+	set.cluster = load("cluster", p.Cluster)
+	set.controlPlane = load("controlplane", p.ControlPlane)
+	set.worker = load("worker", p.Worker)
+	set.nodes = make(map[string][]configpatcher.Patch, len(p.Nodes))
+
+	hostnames := make(map[string]struct{})
+	for _, n := range c.nodes() {
+		hostnames[n.HostName] = struct{}{}
+	}
+
+	for host, patches := range p.Nodes {
+		if _, ok := hostnames[host]; !ok {
+			err = errors.Join(err, fmt.Errorf("patches for unknown node %q", host))
+			continue
+		}
+		set.nodes[host] = load("node "+host, patches)
+	}
+
+	c.patches = set
+	return c, err
+}
+
+func (c Config) patchesFor(machineType machine.Type, hostName string) []configpatcher.Patch {
+	patches := append([]configpatcher.Patch{}, c.patches.cluster...)
+	if machineType == machine.TypeControlPlane {
+		patches = append(patches, c.patches.controlPlane...)
+	} else {
+		patches = append(patches, c.patches.worker...)
+	}
+
+	return append(patches, c.patches.nodes[hostName]...)
+}
+
+// applyPatches applies patches in order. The machinery only supports JSON
+// patches on single-document configs, so those are applied to the v1alpha1
+// document alone and the volume documents are carried over unchanged.
+func applyPatches(docs []config.Document, patches []configpatcher.Patch) ([]config.Document, error) {
+	cfg, err := container.New(docs...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, patch := range patches {
+		if _, ok := patch.(configpatcher.StrategicMergePatch); ok {
+			out, err := configpatcher.Apply(configpatcher.WithConfig(cfg), []configpatcher.Patch{patch})
+			if err != nil {
+				return nil, fmt.Errorf("patch %d: %w", i+1, err)
+			}
+
+			patched, err := out.Config()
+			if err != nil {
+				return nil, fmt.Errorf("patch %d: %w", i+1, err)
+			}
+
+			if cfg, err = container.New(patched.Documents()...); err != nil {
+				return nil, fmt.Errorf("patch %d: %w", i+1, err)
+			}
+			continue
+		}
+
+		v1alpha1Config, err := container.New(cfg.RawV1Alpha1())
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := configpatcher.Apply(configpatcher.WithConfig(v1alpha1Config), []configpatcher.Patch{patch})
+		if err != nil {
+			return nil, fmt.Errorf("patch %d: %w", i+1, err)
+		}
+
+		patched, err := out.Config()
+		if err != nil {
+			return nil, fmt.Errorf("patch %d: %w", i+1, err)
+		}
+
+		rest := make([]config.Document, 0, len(cfg.Documents()))
+		for _, doc := range cfg.Documents() {
+			if _, ok := doc.(*v1alpha1.Config); !ok {
+				rest = append(rest, doc)
+			}
+		}
+
+		if cfg, err = container.New(append(patched.Documents(), rest...)...); err != nil {
+			return nil, fmt.Errorf("patch %d: %w", i+1, err)
+		}
+	}
+
+	return cfg.Documents(), nil
+}
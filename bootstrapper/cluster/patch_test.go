@@ -0,0 +1,139 @@
+package cluster_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func testSecrets() cluster.Secrets {
+	return cluster.Secrets{
+		Token:                     "test-token",
+		OSCert:                    "test-os-cert",
+		OSKey:                     "test-os-key",
+		OSAdminCert:               "test-os-admin-cert",
+		OSAdminKey:                "test-os-admin-key",
+		ClusterID:                 "test-cluster-id",
+		ClusterSecret:             "test-cluster-secret",
+		TrustdToken:               "test-trustd-token",
+		BootstrapToken:            "test-bootstrap-token",
+		SecretBoxEncryptionSecret: "test-secretbox",
+		K8SCert:                   "test-k8s-cert",
+		K8SKey:                    "test-k8s-key",
+		K8SAggregatorCert:         "test-k8s-agg-cert",
+		K8SAggregatorKey:          "test-k8s-agg-key",
+		K8SServiceAccount:         "test-k8s-sa",
+		ECTDCert:                  "test-etcd-cert",
+		ECTDKey:                   "test-etcd-key",
+		CiliumCACert:              "test-cilium-ca-cert",
+		CiliumCAKey:               "test-cilium-ca-key",
+		HubbleTLSCert:             "test-hubble-tls-cert",
+		HubbleTLSKey:              "test-hubble-tls-key",
+	}
+}
+
+func patchTestConfig(t *testing.T) cluster.Config {
+	t.Helper()
+
+	cp, err := cluster.NewNodeConfig("cp1", "192.168.1.100", cluster.StorageTypeNVMe, 100, 200)
+	require.NoError(t, err)
+
+	worker, err := cluster.NewNodeConfig("worker1", "192.168.1.101", cluster.StorageTypeMMC, 50, 150)
+	require.NoError(t, err)
+
+	cfg, err := cluster.NewConfig("test-cluster", "192.168.1.100", testSecrets(), []cluster.NodeConfig{cp}, []cluster.NodeConfig{worker})
+	require.NoError(t, err)
+
+	return cfg
+}
+
+func readDocuments(t *testing.T, path string) []map[string]any {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var docs []map[string]any
+	decoder := yaml.NewDecoder(f)
+	for {
+		var doc map[string]any
+		if err := decoder.Decode(&doc); err != nil {
+			break
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs
+}
+
+func TestWithPatches(t *testing.T) {
+	t.Run("applies strategic merge patches in order", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		cfg, err := patchTestConfig(t).WithPatches(cluster.Patches{
+			Cluster:      []string{"machine:\n  sysctls:\n    net.core.somaxconn: \"1024\"\n    vm.nr_hugepages: \"64\"\n"},
+			ControlPlane: []string{"machine:\n  sysctls:\n    vm.nr_hugepages: \"128\"\n"},
+			Nodes: map[string][]string{
+				"worker1": {"machine:\n  sysctls:\n    vm.nr_hugepages: \"256\"\n"},
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		cp := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))
+		require.Len(t, cp, 3)
+		sysctls := cp[0]["machine"].(map[string]any)["sysctls"].(map[string]any)
+		assert.Equal(t, "1024", sysctls["net.core.somaxconn"])
+		assert.Equal(t, "128", sysctls["vm.nr_hugepages"])
+
+		worker := readDocuments(t, filepath.Join(tmpDir, "test-cluster-worker1-worker.yaml"))
+		require.Len(t, worker, 3)
+		sysctls = worker[0]["machine"].(map[string]any)["sysctls"].(map[string]any)
+		assert.Equal(t, "1024", sysctls["net.core.somaxconn"])
+		assert.Equal(t, "256", sysctls["vm.nr_hugepages"])
+	})
+
+	t.Run("applies JSON patches and keeps volume documents", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		cfg, err := patchTestConfig(t).WithPatches(cluster.Patches{
+			Worker: []string{`[{"op": "replace", "path": "/machine/time/servers", "value": ["pool.ntp.org"]}]`},
+		})
+		require.NoError(t, err)
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-worker1-worker.yaml"))
+		require.Len(t, docs, 3)
+		servers := docs[0]["machine"].(map[string]any)["time"].(map[string]any)["servers"]
+		assert.Equal(t, []any{"pool.ntp.org"}, servers)
+		assert.Equal(t, "EPHEMERAL", docs[1]["name"])
+		assert.Equal(t, "persistent-data", docs[2]["name"])
+	})
+
+	t.Run("rejects patches for unknown nodes", func(t *testing.T) {
+		_, err := patchTestConfig(t).WithPatches(cluster.Patches{
+			Nodes: map[string][]string{"joker": {"machine: {}"}},
+		})
+		assert.ErrorContains(t, err, `unknown node "joker"`)
+	})
+
+	t.Run("reports patches that break validation per node", func(t *testing.T) {
+		cfg, err := patchTestConfig(t).WithPatches(cluster.Patches{
+			Nodes: map[string][]string{
+				"worker1": {`[{"op": "remove", "path": "/machine/install/disk"}]`},
+			},
+		})
+		require.NoError(t, err)
+
+		err = cfg.GenerateConfigs(t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "worker worker1")
+		assert.NotContains(t, err.Error(), "cp1")
+	})
+}
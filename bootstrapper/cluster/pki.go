@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PKISpec describes the offline homelab root CA and the intermediate CAs
+// it signs for in-cluster issuers such as cert-manager CA issuers. The
+// root key never leaves the secrets file; only intermediates are written
+// out as Kubernetes Secret manifests.
+type PKISpec struct {
+	Root          CertOptions        `yaml:"root"`
+	Intermediates []IntermediateSpec `yaml:"intermediates"`
+}
+
+// IntermediateSpec is an intermediate CA and the Secret it is written to.
+// SecretName defaults to "<name>-intermediate-ca". Unless MaxPathLen says otherwise the
+// intermediate may only sign leaf certificates.
+type IntermediateSpec struct {
+	Name        string `yaml:"name"`
+	Namespace   string `yaml:"namespace"`
+	SecretName  string `yaml:"secretName"`
+	CertOptions `yaml:",inline"`
+}
+
+// rootCACommonName is the homelab root CA's default common name.
+const rootCACommonName = "Homelab Root CA"
+
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// reservedSecrets are Secrets the Cilium inline manifest and its
+// cert-manager Certificates already own; an intermediate written over
+// them would replace the Cilium CA.
+var reservedSecrets = []string{"cilium/cilium-ca", "cilium/hubble-server-certs"}
+
+func (p PKISpec) Validate() error {
+	err := prefixErrors("root", p.Root.validate(true))
+
+	names := make(map[string]struct{})
+	secrets := make(map[string]string)
+	for i, im := range p.Intermediates {
+		prefix := fmt.Sprintf("intermediate %q", im.Name)
+		if im.Name == "" {
+			prefix = fmt.Sprintf("intermediate #%d", i+1)
+			err = errors.Join(err, fmt.Errorf("%s: name is required", prefix))
+		} else if !dnsLabel.MatchString(im.Name) {
+			err = errors.Join(err, fmt.Errorf("%s: name must be a lowercase DNS label", prefix))
+		}
+		if _, ok := names[im.Name]; ok && im.Name != "" {
+			err = errors.Join(err, fmt.Errorf("%s: duplicate name", prefix))
+		}
+		names[im.Name] = struct{}{}
+
+		if im.Namespace == "" {
+			err = errors.Join(err, fmt.Errorf("%s: namespace is required", prefix))
+		}
+		secret := im.Namespace + "/" + im.secretName()
+		if owner, ok := secrets[secret]; ok {
+			err = errors.Join(err, fmt.Errorf("%s: secret %s is already used by %q", prefix, secret, owner))
+		}
+		if slices.Contains(reservedSecrets, secret) {
+			err = errors.Join(err, fmt.Errorf("%s: secret %s belongs to Cilium", prefix, secret))
+		}
+		secrets[secret] = im.Name
+
+		err = errors.Join(err, prefixErrors(prefix, im.CertOptions.validate(true)))
+	}
+
+	return err
+}
+
+func (im IntermediateSpec) secretName() string {
+	if im.SecretName != "" {
+		return im.SecretName
+	}
+	return im.Name + "-intermediate-ca"
+}
+
+// HomelabPKI is the offline root CA and the intermediates it signed, keyed
+// by name.
+type HomelabPKI struct {
+	Root          CAKeyPair            `json:"root"`
+	Intermediates map[string]CAKeyPair `json:"intermediates,omitempty"`
+}
+
+func (p HomelabPKI) Validate() error {
+	var err error
+	if p.Root.Cert == "" || p.Root.Key == "" {
+		err = errors.Join(err, errors.New("root CA needs a certificate and key"))
+	}
+	for name, im := range p.Intermediates {
+		if im.Cert == "" || im.Key == "" {
+			err = errors.Join(err, fmt.Errorf("intermediate %q needs a certificate and key", name))
+		}
+	}
+	return err
+}
+
+// EnsureRootCA creates the homelab root CA from opts unless the secrets
+// already hold one. It reports whether a root was created.
+func (cs *Secrets) EnsureRootCA(opts CertOptions) (bool, error) {
+	if cs.PKI != nil {
+		return false, nil
+	}
+
+	crt, key, err := generateCACert(rootCACommonName, opts)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate root CA: %w", err)
+	}
+
+	cs.PKI = &HomelabPKI{Root: CAKeyPair{Cert: crt, Key: key}}
+	return true, nil
+}
+
+// HasIntermediate reports whether an intermediate named name was issued.
+func (cs Secrets) HasIntermediate(name string) bool {
+	if cs.PKI == nil {
+		return false
+	}
+	_, ok := cs.PKI.Intermediates[name]
+	return ok
+}
+
+// IssueIntermediate signs a new intermediate CA, with a new key, from the
+// root CA, replacing any earlier one of the same name.
+func (cs *Secrets) IssueIntermediate(im IntermediateSpec) error {
+	if cs.PKI == nil {
+		return errors.New("no root CA to issue intermediates from")
+	}
+
+	opts := im.CertOptions
+	if opts.MaxPathLen == nil {
+		zero := 0
+		opts.MaxPathLen = &zero
+	}
+
+	crt, key, err := generateIntermediateCACert(cs.PKI.Root.Cert, cs.PKI.Root.Key, "Homelab "+im.Name+" CA", opts)
+	if err != nil {
+		return fmt.Errorf("failed to issue intermediate %q: %w", im.Name, err)
+	}
+
+	if cs.PKI.Intermediates == nil {
+		cs.PKI.Intermediates = map[string]CAKeyPair{}
+	}
+	cs.PKI.Intermediates[im.Name] = CAKeyPair{Cert: crt, Key: key}
+	return nil
+}
+
+type secretManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   secretMetadata    `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type secretMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+// IntermediateManifests renders a kubernetes.io/tls Secret for every
+// intermediate in the spec, named "<name>.yaml". tls.crt holds the
+// intermediate followed by the root, which is also ca.crt, so a
+// cert-manager CA issuer serves the full chain.
+func (p PKISpec) IntermediateManifests(cs Secrets) ([]RenderedFile, error) {
+	var (
+		err   error
+		files []RenderedFile
+	)
+	for _, im := range p.Intermediates {
+		if !cs.HasIntermediate(im.Name) {
+			err = errors.Join(err, fmt.Errorf("intermediate %q has not been issued", im.Name))
+			continue
+		}
+		pair := cs.PKI.Intermediates[im.Name]
+
+		chain := pair.Cert
+		if !strings.HasSuffix(chain, "\n") {
+			chain += "\n"
+		}
+		chain += cs.PKI.Root.Cert
+
+		data, mErr := yaml.Marshal(secretManifest{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata: secretMetadata{
+				Name:      im.secretName(),
+				Namespace: im.Namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": "bootstrapper"},
+			},
+			Type: "kubernetes.io/tls",
+			Data: map[string]string{
+				"tls.crt": base64.StdEncoding.EncodeToString([]byte(chain)),
+				"tls.key": base64.StdEncoding.EncodeToString([]byte(pair.Key)),
+				"ca.crt":  base64.StdEncoding.EncodeToString([]byte(cs.PKI.Root.Cert)),
+			},
+		})
+		if mErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to render intermediate %q: %w", im.Name, mErr))
+			continue
+		}
+
+		files = append(files, RenderedFile{Name: im.Name + ".yaml", Data: data})
+	}
+
+	return files, err
+}
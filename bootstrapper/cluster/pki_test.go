@@ -0,0 +1,157 @@
+package cluster_test
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKISpecValidation(t *testing.T) {
+	t.Run("parses intermediates with inline options", func(t *testing.T) {
+		spec, err := cluster.ParseSpec([]byte(`version: v1
+pki:
+  root:
+    validity: 87600h
+  intermediates:
+    - name: traefik
+      namespace: edge
+      keyAlgorithm: ecdsa-p384
+      validity: 8760h
+`))
+		require.NoError(t, err)
+
+		require.Len(t, spec.PKI.Intermediates, 1)
+		im := spec.PKI.Intermediates[0]
+		assert.Equal(t, "traefik", im.Name)
+		assert.Equal(t, cluster.KeyECDSAP384, im.KeyAlgorithm)
+		assert.Equal(t, 8760*time.Hour, im.Validity)
+	})
+
+	t.Run("rejects bad intermediates", func(t *testing.T) {
+		err := cluster.PKISpec{
+			Intermediates: []cluster.IntermediateSpec{
+				{Name: "cilium", Namespace: "cilium"},
+				{Name: "cilium", Namespace: "cilium", SecretName: "other"},
+				{Name: "Traefik"},
+				{Name: "pg", Namespace: "cilium", SecretName: "cilium-intermediate-ca"},
+				{Name: "hubble", Namespace: "cilium", SecretName: "hubble-server-certs"},
+				{Name: "ca", Namespace: "cilium", SecretName: "cilium-ca"},
+				{Namespace: "db"},
+			},
+		}.Validate()
+
+		assert.ErrorContains(t, err, `intermediate "cilium": duplicate name`)
+		assert.ErrorContains(t, err, `intermediate "Traefik": name must be a lowercase DNS label`)
+		assert.ErrorContains(t, err, `intermediate "Traefik": namespace is required`)
+		assert.ErrorContains(t, err, `intermediate "pg": secret cilium/cilium-intermediate-ca is already used by "cilium"`)
+		assert.ErrorContains(t, err, `intermediate "hubble": secret cilium/hubble-server-certs belongs to Cilium`)
+		assert.ErrorContains(t, err, `intermediate "ca": secret cilium/cilium-ca belongs to Cilium`)
+		assert.ErrorContains(t, err, "intermediate #7: name is required")
+	})
+}
+
+func TestHomelabPKI(t *testing.T) {
+	cs := generatedSecrets(t)
+
+	created, err := cs.EnsureRootCA(cluster.CertOptions{Validity: 365 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	root := cs.PKI.Root
+	created, err = cs.EnsureRootCA(cluster.CertOptions{})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, root, cs.PKI.Root)
+
+	spec := cluster.PKISpec{Intermediates: []cluster.IntermediateSpec{
+		{Name: "cilium", Namespace: "cilium", SecretName: "homelab-intermediate-ca"},
+		{Name: "postgres", Namespace: "datamonster", CertOptions: cluster.CertOptions{Validity: 10 * 365 * 24 * time.Hour}},
+	}}
+
+	_, err = spec.IntermediateManifests(cs)
+	assert.ErrorContains(t, err, `intermediate "cilium" has not been issued`)
+
+	for _, im := range spec.Intermediates {
+		require.NoError(t, cs.IssueIntermediate(im))
+	}
+	require.NoError(t, cs.Validate())
+	assert.True(t, cs.HasIntermediate("postgres"))
+
+	t.Run("intermediates chain to the root", func(t *testing.T) {
+		crt := parseCert(t, cs.PKI.Intermediates["cilium"].Cert)
+		assert.True(t, crt.IsCA)
+		assert.Equal(t, 0, crt.MaxPathLen)
+		assert.True(t, crt.MaxPathLenZero)
+		assert.Equal(t, "Homelab cilium CA", crt.Subject.CommonName)
+		assert.NoError(t, verifies(t, cs.PKI.Intermediates["cilium"].Cert, root.Cert, x509.ExtKeyUsageAny))
+	})
+
+	t.Run("intermediates never outlive the root", func(t *testing.T) {
+		assert.Equal(t, parseCert(t, root.Cert).NotAfter, parseCert(t, cs.PKI.Intermediates["postgres"].Cert).NotAfter)
+	})
+
+	t.Run("manifests", func(t *testing.T) {
+		files, err := spec.IntermediateManifests(cs)
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		assert.Equal(t, "cilium.yaml", files[0].Name)
+
+		dir := t.TempDir()
+		require.NoError(t, cluster.WriteFiles(dir, files))
+		info, err := os.Stat(filepath.Join(dir, "postgres.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		doc := readDocuments(t, filepath.Join(dir, "postgres.yaml"))[0]
+		assert.Equal(t, "Secret", doc["kind"])
+		assert.Equal(t, "kubernetes.io/tls", doc["type"])
+		metadata := doc["metadata"].(map[string]any)
+		assert.Equal(t, "postgres-intermediate-ca", metadata["name"])
+		assert.Equal(t, "datamonster", metadata["namespace"])
+
+		data := doc["data"].(map[string]any)
+		decode := func(key string) string {
+			b, err := base64.StdEncoding.DecodeString(data[key].(string))
+			require.NoError(t, err)
+			return string(b)
+		}
+		pair := cs.PKI.Intermediates["postgres"]
+		assert.Equal(t, pair.Cert+root.Cert, decode("tls.crt"))
+		assert.Equal(t, pair.Key, decode("tls.key"))
+		assert.Equal(t, root.Cert, decode("ca.crt"))
+	})
+
+	t.Run("renewal replaces the key", func(t *testing.T) {
+		before := cs.PKI.Intermediates["cilium"]
+		require.NoError(t, cs.IssueIntermediate(spec.Intermediates[0]))
+		assert.NotEqual(t, before.Key, cs.PKI.Intermediates["cilium"].Key)
+		assert.Equal(t, root, cs.PKI.Root)
+	})
+
+	t.Run("certificates lists the hierarchy", func(t *testing.T) {
+		certs, err := cs.Certificates()
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range certs {
+			names = append(names, c.Name)
+		}
+		assert.Contains(t, names, "homelab root CA")
+		assert.Contains(t, names, "homelab postgres intermediate CA")
+	})
+}
+
+func TestIssueIntermediateNeedsRoot(t *testing.T) {
+	cs := generatedSecrets(t)
+	assert.ErrorContains(t, cs.IssueIntermediate(cluster.IntermediateSpec{Name: "traefik", Namespace: "edge"}), "no root CA")
+
+	cs.PKI = &cluster.HomelabPKI{}
+	assert.ErrorContains(t, cs.Validate(), "PKI: root CA needs a certificate and key")
+}
@@ -0,0 +1,40 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/siderolabs/talos/pkg/machinery/role"
+)
+
+// RenewHubbleCert re-signs the Hubble server certificate, with a new key
+// following opts, from the existing Cilium CA. The CA stays the same, so
+// Hubble clients keep trusting the server.
+func (cs *Secrets) RenewHubbleCert(opts CertOptions) error {
+	crt, key, err := generateTLSCert(cs.CiliumCACert, cs.CiliumCAKey, hubbleServerName, opts)
+	if err != nil {
+		return fmt.Errorf("failed to renew hubble certificate: %w", err)
+	}
+
+	cs.HubbleTLSCert, cs.HubbleTLSKey = crt, key
+	return nil
+}
+
+// RenewAdminCert re-signs the Talos admin client certificate, with a new
+// key following opts, from the existing OS CA. Unless opts say otherwise it
+// is valid for DefaultTalosconfigLifetime.
+func (cs *Secrets) RenewAdminCert(opts CertOptions) error {
+	crt, key, err := generateClientCert(cs.OSCert, cs.OSKey, "", []string{string(role.Admin)}, opts.withDefaults(KeyEd25519, DefaultTalosconfigLifetime))
+	if err != nil {
+		return fmt.Errorf("failed to renew admin certificate: %w", err)
+	}
+
+	cs.OSAdminCert, cs.OSAdminKey = crt, key
+	return nil
+}
+
+// WithSecrets returns a copy of the config using s, validated against the
+// nodes.
+func (c Config) WithSecrets(s Secrets) (Config, error) {
+	c.secrets = s
+	return c, c.Validate()
+}
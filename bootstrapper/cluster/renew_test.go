@@ -0,0 +1,83 @@
+package cluster_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseCert(t *testing.T, data string) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode([]byte(data))
+	require.NotNil(t, block)
+	crt, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return crt
+}
+
+func verifies(t *testing.T, leaf, ca string, usage x509.ExtKeyUsage) error {
+	t.Helper()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(parseCert(t, ca))
+	_, err := parseCert(t, leaf).Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{usage}})
+	return err
+}
+
+func TestRenewHubbleCert(t *testing.T) {
+	cs := generatedSecrets(t)
+	renewed := cs
+
+	require.NoError(t, renewed.RenewHubbleCert(cluster.CertOptions{}))
+
+	assert.Equal(t, cs.CiliumCACert, renewed.CiliumCACert)
+	assert.Equal(t, cs.CiliumCAKey, renewed.CiliumCAKey)
+	assert.NotEqual(t, cs.HubbleTLSCert, renewed.HubbleTLSCert)
+	assert.NotEqual(t, cs.HubbleTLSKey, renewed.HubbleTLSKey)
+
+	assert.NoError(t, verifies(t, renewed.HubbleTLSCert, cs.CiliumCACert, x509.ExtKeyUsageServerAuth))
+	assert.Equal(t, "*.default.hubble-grpc.cilium.io", parseCert(t, renewed.HubbleTLSCert).Subject.CommonName)
+
+	t.Run("rejects a broken CA", func(t *testing.T) {
+		broken := cs
+		broken.CiliumCAKey = "not a key"
+
+		assert.ErrorContains(t, broken.RenewHubbleCert(cluster.CertOptions{}), "failed to renew hubble certificate")
+	})
+}
+
+func TestRenewAdminCert(t *testing.T) {
+	cs := generatedSecrets(t)
+	renewed := cs
+
+	require.NoError(t, renewed.RenewAdminCert(cluster.CertOptions{Validity: 48 * time.Hour}))
+
+	assert.Equal(t, cs.OSCert, renewed.OSCert)
+	assert.Equal(t, cs.OSKey, renewed.OSKey)
+	assert.NotEqual(t, cs.OSAdminCert, renewed.OSAdminCert)
+
+	assert.NoError(t, verifies(t, renewed.OSAdminCert, cs.OSCert, x509.ExtKeyUsageClientAuth))
+
+	crt := parseCert(t, renewed.OSAdminCert)
+	assert.Equal(t, []string{"os:admin"}, crt.Subject.Organization)
+	assert.Equal(t, x509.Ed25519, crt.PublicKeyAlgorithm)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), crt.NotAfter, time.Hour)
+
+	t.Run("follows options", func(t *testing.T) {
+		require.NoError(t, renewed.RenewAdminCert(cluster.CertOptions{
+			KeyAlgorithm: cluster.KeyECDSAP384,
+			Subject:      cluster.CertSubject{CommonName: "homelab-admin", OrganizationalUnit: []string{"ops"}},
+		}))
+
+		crt := parseCert(t, renewed.OSAdminCert)
+		assert.Equal(t, x509.ECDSA, crt.PublicKeyAlgorithm)
+		assert.Equal(t, "CN=homelab-admin,OU=ops,O=os:admin", crt.Subject.String())
+		assert.WithinDuration(t, time.Now().Add(cluster.DefaultTalosconfigLifetime), crt.NotAfter, time.Minute)
+	})
+}
@@ -0,0 +1,284 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/siderolabs/crypto/x509"
+	"github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+)
+
+// CA names a certificate authority that can be rotated.
+type CA string
+
+const (
+	CAOS         CA = "os"
+	CAKubernetes CA = "kubernetes"
+	CAEtcd       CA = "etcd"
+	CACilium     CA = "cilium"
+)
+
+// RotatableCAs lists every CA a rotation can replace.
+var RotatableCAs = []CA{CAOS, CAKubernetes, CAEtcd, CACilium}
+
+// caLabels name each CA the way Certificates reports it.
+var caLabels = map[CA]string{
+	CAOS:         "Talos OS CA",
+	CAKubernetes: "kubernetes CA",
+	CAEtcd:       "etcd CA",
+	CACilium:     "cilium CA",
+}
+
+// ParseCAs parses a comma-separated list of CA names.
+func ParseCAs(s string) ([]CA, error) {
+	var (
+		err error
+		cas []CA
+	)
+	for _, name := range strings.Split(s, ",") {
+		ca := CA(strings.TrimSpace(name))
+		switch {
+		case !slices.Contains(RotatableCAs, ca):
+			err = errors.Join(err, fmt.Errorf("unknown CA %q, expected one of %v", ca, RotatableCAs))
+		case !slices.Contains(cas, ca):
+			cas = append(cas, ca)
+		}
+	}
+	return cas, err
+}
+
+// RotationPhase is the step a staged CA rotation has reached. Each phase
+// is applied to every node and verified before moving to the next:
+//
+//   - trust: the new CAs are trusted next to the old ones, which still
+//     issue every certificate.
+//   - issue: the new CAs issue; the old ones are still trusted so nodes and
+//     clients that have not picked up new certificates keep working.
+//
+// Finishing the rotation drops the old CAs.
+type RotationPhase string
+
+const (
+	RotationTrust RotationPhase = "trust"
+	RotationIssue RotationPhase = "issue"
+)
+
+// CARotation is the state of a CA rotation in progress.
+type CARotation struct {
+	Phase RotationPhase `json:"phase"`
+	// Pending are the new CAs during the trust phase.
+	Pending map[CA]CAKeyPair `json:"pending,omitempty"`
+	// Retired are the old CA certificates during the issue phase.
+	Retired map[CA]string `json:"retired,omitempty"`
+}
+
+// CAKeyPair is a PEM encoded CA certificate and key.
+type CAKeyPair struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+// CAs lists the CAs being rotated.
+func (r CARotation) CAs() []CA {
+	var cas []CA
+	for _, ca := range RotatableCAs {
+		_, pending := r.Pending[ca]
+		_, retired := r.Retired[ca]
+		if pending || retired {
+			cas = append(cas, ca)
+		}
+	}
+	return cas
+}
+
+func (r CARotation) Validate() error {
+	var err error
+	switch r.Phase {
+	case RotationTrust:
+		if len(r.Pending) == 0 || len(r.Retired) > 0 {
+			err = errors.Join(err, errors.New("trust phase needs pending CAs and no retired ones"))
+		}
+		for ca, p := range r.Pending {
+			if p.Cert == "" || p.Key == "" {
+				err = errors.Join(err, fmt.Errorf("pending %s CA needs a certificate and key", ca))
+			}
+		}
+	case RotationIssue:
+		if len(r.Retired) == 0 || len(r.Pending) > 0 {
+			err = errors.Join(err, errors.New("issue phase needs retired CAs and no pending ones"))
+		}
+		for ca, crt := range r.Retired {
+			if crt == "" {
+				err = errors.Join(err, fmt.Errorf("retired %s CA needs a certificate", ca))
+			}
+		}
+	default:
+		err = errors.Join(err, fmt.Errorf("unknown phase %q", r.Phase))
+	}
+
+	for ca := range r.Pending {
+		if !slices.Contains(RotatableCAs, ca) {
+			err = errors.Join(err, fmt.Errorf("unknown CA %q", ca))
+		}
+	}
+	for ca := range r.Retired {
+		if !slices.Contains(RotatableCAs, ca) {
+			err = errors.Join(err, fmt.Errorf("unknown CA %q", ca))
+		}
+	}
+
+	return err
+}
+
+// BeginCARotation generates a new CA for each of cas and enters the trust
+// phase. Until SwitchCAs the current CAs keep issuing. A new Cilium CA
+// follows policy.
+func (cs *Secrets) BeginCARotation(cas []CA, policy CertPolicy) error {
+	if cs.Rotation != nil {
+		return fmt.Errorf("a CA rotation is already in the %s phase", cs.Rotation.Phase)
+	}
+	if len(cas) == 0 {
+		return errors.New("no CAs to rotate")
+	}
+
+	pending := make(map[CA]CAKeyPair, len(cas))
+	for _, ca := range cas {
+		p, err := newCA(ca, policy)
+		if err != nil {
+			return fmt.Errorf("failed to generate %s CA: %w", ca, err)
+		}
+		pending[ca] = p
+	}
+
+	cs.Rotation = &CARotation{Phase: RotationTrust, Pending: pending}
+	return nil
+}
+
+// SwitchCAs makes the pending CAs issue and keeps the old ones as trusted
+// roots. The admin client and Hubble server certificates are re-signed by
+// their new CAs following policy.
+func (cs *Secrets) SwitchCAs(policy CertPolicy) error {
+	if cs.Rotation == nil || cs.Rotation.Phase != RotationTrust {
+		return errors.New("no CA rotation in the trust phase")
+	}
+
+	retired := make(map[CA]string, len(cs.Rotation.Pending))
+	for ca, p := range cs.Rotation.Pending {
+		crt, key := cs.ca(ca)
+		retired[ca] = *crt
+		*crt, *key = p.Cert, p.Key
+	}
+
+	if _, ok := retired[CAOS]; ok {
+		if err := cs.RenewAdminCert(policy.TalosClient); err != nil {
+			return err
+		}
+	}
+	if _, ok := retired[CACilium]; ok {
+		if err := cs.RenewHubbleCert(policy.Hubble); err != nil {
+			return err
+		}
+	}
+
+	cs.Rotation = &CARotation{Phase: RotationIssue, Retired: retired}
+	return nil
+}
+
+// FinishCARotation drops the old CAs once every node issues from the new
+// ones.
+func (cs *Secrets) FinishCARotation() error {
+	if cs.Rotation == nil || cs.Rotation.Phase != RotationIssue {
+		return errors.New("no CA rotation in the issue phase")
+	}
+
+	cs.Rotation = nil
+	return nil
+}
+
+// AcceptedCAs returns the certificates trusted next to the issuing CA:
+// the new CA during the trust phase and the old one during the issue
+// phase.
+func (cs Secrets) AcceptedCAs(ca CA) []string {
+	if cs.Rotation == nil {
+		return nil
+	}
+	if p, ok := cs.Rotation.Pending[ca]; ok {
+		return []string{p.Cert}
+	}
+	if crt, ok := cs.Rotation.Retired[ca]; ok {
+		return []string{crt}
+	}
+	return nil
+}
+
+// TrustBundle returns the issuing CA certificate followed by any accepted
+// ones. Consumers that pair the bundle with the CA key sign with the first
+// certificate.
+func (cs Secrets) TrustBundle(ca CA) string {
+	crt, _ := cs.ca(ca)
+	bundle := *crt
+	for _, accepted := range cs.AcceptedCAs(ca) {
+		if !strings.HasSuffix(bundle, "\n") {
+			bundle += "\n"
+		}
+		bundle += accepted
+	}
+	return bundle
+}
+
+// acceptedCACerts returns AcceptedCAs in machine config form.
+func (cs Secrets) acceptedCACerts(ca CA) []*x509.PEMEncodedCertificate {
+	var certs []*x509.PEMEncodedCertificate
+	for _, crt := range cs.AcceptedCAs(ca) {
+		certs = append(certs, &x509.PEMEncodedCertificate{Crt: []byte(crt)})
+	}
+	return certs
+}
+
+// ca returns the secrets fields holding the issuing certificate and key of
+// ca.
+func (cs *Secrets) ca(ca CA) (crt, key *string) {
+	switch ca {
+	case CAOS:
+		return &cs.OSCert, &cs.OSKey
+	case CAKubernetes:
+		return &cs.K8SCert, &cs.K8SKey
+	case CAEtcd:
+		return &cs.ECTDCert, &cs.ECTDKey
+	case CACilium:
+		return &cs.CiliumCACert, &cs.CiliumCAKey
+	default:
+		panic(fmt.Sprintf("unknown CA %q", ca))
+	}
+}
+
+func newCA(ca CA, policy CertPolicy) (CAKeyPair, error) {
+	now := time.Now()
+
+	var (
+		authority *x509.CertificateAuthority
+		err       error
+	)
+	switch ca {
+	case CAOS:
+		authority, err = secrets.NewTalosCA(now)
+	case CAKubernetes:
+		authority, err = secrets.NewKubernetesCA(now, config.TalosVersionCurrent)
+	case CAEtcd:
+		authority, err = secrets.NewEtcdCA(now, config.TalosVersionCurrent)
+	case CACilium:
+		crt, key, err := generateCACert("Cilium CA", policy.CiliumCA)
+		return CAKeyPair{Cert: crt, Key: key}, err
+	default:
+		return CAKeyPair{}, fmt.Errorf("unknown CA %q", ca)
+	}
+	if err != nil {
+		return CAKeyPair{}, err
+	}
+
+	return CAKeyPair{Cert: string(authority.CrtPEM), Key: string(authority.KeyPEM)}, nil
+}
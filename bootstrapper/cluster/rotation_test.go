@@ -0,0 +1,165 @@
+package cluster_test
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rotationConfig(t *testing.T, cs cluster.Secrets) (controlPlane, worker map[string]any) {
+	t.Helper()
+
+	cp, err := cluster.NewNodeConfig("cp1", "192.168.1.100", cluster.StorageTypeNVMe, 100, 200)
+	require.NoError(t, err)
+	w, err := cluster.NewNodeConfig("worker1", "192.168.1.101", cluster.StorageTypeMMC, 50, 150)
+	require.NoError(t, err)
+
+	cfg, err := cluster.NewConfig("test-cluster", "192.168.1.100", cs, []cluster.NodeConfig{cp}, []cluster.NodeConfig{w})
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+	return readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))[0],
+		readDocuments(t, filepath.Join(tmpDir, "test-cluster-worker1-worker.yaml"))[0]
+}
+
+func acceptedCAs(t *testing.T, doc map[string]any, section string) []string {
+	t.Helper()
+
+	list, _ := doc[section].(map[string]any)["acceptedCAs"].([]any)
+	var certs []string
+	for _, entry := range list {
+		crt, err := base64.StdEncoding.DecodeString(entry.(map[string]any)["crt"].(string))
+		require.NoError(t, err)
+		certs = append(certs, string(crt))
+	}
+	return certs
+}
+
+func etcdCA(t *testing.T, doc map[string]any) string {
+	t.Helper()
+
+	ca := doc["cluster"].(map[string]any)["etcd"].(map[string]any)["ca"].(map[string]any)
+	crt, err := base64.StdEncoding.DecodeString(ca["crt"].(string))
+	require.NoError(t, err)
+	return string(crt)
+}
+
+func TestCARotation(t *testing.T) {
+	old := generatedSecrets(t)
+	cs := old
+
+	require.NoError(t, cs.BeginCARotation(cluster.RotatableCAs, cluster.CertPolicy{}))
+	require.NoError(t, cs.Validate())
+
+	t.Run("trust phase keeps the old CAs issuing", func(t *testing.T) {
+		assert.Equal(t, old.OSCert, cs.OSCert)
+		assert.Equal(t, old.OSAdminCert, cs.OSAdminCert)
+		assert.Equal(t, cluster.RotatableCAs, cs.Rotation.CAs())
+
+		newOS := cs.Rotation.Pending[cluster.CAOS].Cert
+		assert.NotEqual(t, old.OSCert, newOS)
+		assert.Equal(t, []string{newOS}, cs.AcceptedCAs(cluster.CAOS))
+		assert.True(t, strings.HasPrefix(cs.TrustBundle(cluster.CAEtcd), old.ECTDCert))
+
+		cp, worker := rotationConfig(t, cs)
+		assert.Equal(t, []string{newOS}, acceptedCAs(t, cp, "machine"))
+		assert.Equal(t, []string{newOS}, acceptedCAs(t, worker, "machine"))
+		assert.Equal(t, []string{cs.Rotation.Pending[cluster.CAKubernetes].Cert}, acceptedCAs(t, worker, "cluster"))
+		assert.Equal(t, old.ECTDCert+cs.Rotation.Pending[cluster.CAEtcd].Cert, etcdCA(t, cp))
+
+		certs, err := cs.Certificates()
+		require.NoError(t, err)
+		var names []string
+		for _, c := range certs {
+			names = append(names, c.Name)
+		}
+		assert.Contains(t, names, "etcd CA (pending)")
+	})
+
+	t.Run("cannot begin twice", func(t *testing.T) {
+		again := cs
+		assert.ErrorContains(t, again.BeginCARotation([]cluster.CA{cluster.CAOS}, cluster.CertPolicy{}), "already in the trust phase")
+	})
+
+	pending := cs.Rotation.Pending
+	require.NoError(t, cs.SwitchCAs(cluster.CertPolicy{TalosClient: cluster.CertOptions{Validity: 24 * time.Hour}}))
+	require.NoError(t, cs.Validate())
+
+	t.Run("issue phase switches to the new CAs", func(t *testing.T) {
+		assert.Equal(t, pending[cluster.CAOS].Cert, cs.OSCert)
+		assert.Equal(t, pending[cluster.CACilium].Key, cs.CiliumCAKey)
+		assert.Equal(t, []string{old.OSCert}, cs.AcceptedCAs(cluster.CAOS))
+
+		assert.NoError(t, verifies(t, cs.OSAdminCert, cs.OSCert, x509.ExtKeyUsageClientAuth))
+		assert.NoError(t, verifies(t, cs.HubbleTLSCert, cs.CiliumCACert, x509.ExtKeyUsageServerAuth))
+
+		cp, _ := rotationConfig(t, cs)
+		assert.Equal(t, []string{old.K8SCert}, acceptedCAs(t, cp, "cluster"))
+		assert.Equal(t, cs.ECTDCert+old.ECTDCert, etcdCA(t, cp))
+	})
+
+	require.NoError(t, cs.FinishCARotation())
+
+	t.Run("finish drops the old CAs", func(t *testing.T) {
+		assert.Nil(t, cs.Rotation)
+		assert.Equal(t, cs.OSCert, cs.TrustBundle(cluster.CAOS))
+
+		cp, worker := rotationConfig(t, cs)
+		assert.Empty(t, acceptedCAs(t, cp, "machine"))
+		assert.Empty(t, acceptedCAs(t, worker, "cluster"))
+		assert.Equal(t, cs.ECTDCert, etcdCA(t, cp))
+	})
+
+	t.Run("phases must run in order", func(t *testing.T) {
+		assert.ErrorContains(t, cs.SwitchCAs(cluster.CertPolicy{}), "no CA rotation in the trust phase")
+		assert.ErrorContains(t, cs.FinishCARotation(), "no CA rotation in the issue phase")
+	})
+}
+
+func TestCARotationSubset(t *testing.T) {
+	old := generatedSecrets(t)
+	cs := old
+
+	require.NoError(t, cs.BeginCARotation([]cluster.CA{cluster.CAEtcd}, cluster.CertPolicy{}))
+	require.NoError(t, cs.SwitchCAs(cluster.CertPolicy{}))
+
+	assert.Equal(t, []cluster.CA{cluster.CAEtcd}, cs.Rotation.CAs())
+	assert.NotEqual(t, old.ECTDCert, cs.ECTDCert)
+	assert.Equal(t, old.OSAdminCert, cs.OSAdminCert)
+	assert.Equal(t, old.HubbleTLSCert, cs.HubbleTLSCert)
+	assert.Empty(t, cs.AcceptedCAs(cluster.CAOS))
+}
+
+func TestCARotationValidation(t *testing.T) {
+	cs := generatedSecrets(t)
+
+	cs.Rotation = &cluster.CARotation{Phase: "bogus"}
+	assert.ErrorContains(t, cs.Validate(), `CA rotation: unknown phase "bogus"`)
+
+	cs.Rotation = &cluster.CARotation{
+		Phase:   cluster.RotationTrust,
+		Pending: map[cluster.CA]cluster.CAKeyPair{cluster.CAOS: {Cert: "crt"}},
+	}
+	assert.ErrorContains(t, cs.Validate(), "pending os CA needs a certificate and key")
+
+	cs.Rotation = &cluster.CARotation{Phase: cluster.RotationIssue}
+	assert.ErrorContains(t, cs.Validate(), "issue phase needs retired CAs")
+}
+
+func TestParseCAs(t *testing.T) {
+	cas, err := cluster.ParseCAs("etcd, os,etcd")
+	require.NoError(t, err)
+	assert.Equal(t, []cluster.CA{cluster.CAEtcd, cluster.CAOS}, cas)
+
+	_, err = cluster.ParseCAs("os,aggregator")
+	assert.ErrorContains(t, err, `unknown CA "aggregator"`)
+}
@@ -24,6 +24,14 @@ type Secrets struct {
 	CiliumCAKey               string `json:"ciliumCaKey"`
 	HubbleTLSCert             string `json:"hubbleTlsCert"`
 	HubbleTLSKey              string `json:"hubbleTlsKey"`
+	// VolumeKeys are the LUKS2 passphrases of volumes using static keys,
+	// keyed by "<hostname>/<volume>".
+	VolumeKeys map[string]string `json:"volumeKeys,omitempty"`
+	// Rotation is set while a CA rotation is in progress.
+	Rotation *CARotation `json:"rotation,omitempty"`
+	// PKI is the offline homelab root CA and its intermediates, once
+	// issued.
+	PKI *HomelabPKI `json:"pki,omitempty"`
 }
 
 func (cs Secrets) Validate() error {
@@ -91,6 +99,29 @@ func (cs Secrets) Validate() error {
 	if cs.HubbleTLSKey == "" {
 		err = errors.Join(err, errors.New("hubble TLS key is required"))
 	}
+	if cs.Rotation != nil {
+		err = errors.Join(err, prefixErrors("CA rotation", cs.Rotation.Validate()))
+	}
+	if cs.PKI != nil {
+		err = errors.Join(err, prefixErrors("PKI", cs.PKI.Validate()))
+	}
 
 	return err
 }
+
+// KeepFrom copies the secrets that are not part of the cluster PKI from
+// prev: static volume keys, since new ones would lock their volumes, and the
+// homelab PKI, whose intermediates are in use outside the cluster. It is
+// meant for fresh secrets replacing prev.
+func (cs *Secrets) KeepFrom(prev Secrets) {
+	if len(prev.VolumeKeys) > 0 {
+		cs.VolumeKeys = make(map[string]string, len(prev.VolumeKeys))
+		for id, key := range prev.VolumeKeys {
+			cs.VolumeKeys[id] = key
+		}
+	}
+	if prev.PKI != nil {
+		pki := *prev.PKI
+		cs.PKI = &pki
+	}
+}
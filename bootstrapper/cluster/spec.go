@@ -0,0 +1,272 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const SpecVersion = "v1"
+
+type Spec struct {
+	Version              string         `yaml:"version"`
+	ClusterName          string         `yaml:"clusterName"`
+	ControlPlaneEndpoint string         `yaml:"controlPlaneEndpoint"`
+	ControlPlanes        []NodeSpec     `yaml:"controlPlanes"`
+	Workers              []NodeSpec     `yaml:"workers"`
+	ClusterNetwork       ClusterNetwork `yaml:"clusterNetwork"`
+	Secrets              SecretsSpec    `yaml:"secrets"`
+	PKI                  PKISpec        `yaml:"pki"`
+	Patches              PatchesSpec    `yaml:"patches"`
+}
+
+// SecretsSpec controls where cluster secrets are stored. Path is relative
+// to the spec file; when AgeRecipients is set the file is age encrypted.
+// Certs sets how the certificates the bootstrapper issues itself are made.
+type SecretsSpec struct {
+	Path          string     `yaml:"path"`
+	AgeRecipients []string   `yaml:"ageRecipients"`
+	Certs         CertPolicy `yaml:"certs"`
+}
+
+// Validate requires recipients for a secrets file outside the output
+// directory, which is usually committed, so it is never written in
+// plaintext.
+func (s SecretsSpec) Validate() error {
+	var err error
+	if s.Path != "" && len(s.AgeRecipients) == 0 {
+		err = errors.Join(err, errors.New("secrets.path requires secrets.ageRecipients"))
+	}
+	for i, r := range s.AgeRecipients {
+		if strings.TrimSpace(r) == "" {
+			err = errors.Join(err, fmt.Errorf("secrets.ageRecipients[%d] is empty", i))
+		}
+	}
+
+	return errors.Join(err, prefixErrors("secrets.certs", s.Certs.Validate()))
+}
+
+// PatchesSpec lists machine config patches applied on top of the generated
+// configs, cluster-wide first, then per role, then per hostname.
+type PatchesSpec struct {
+	Cluster      []PatchSource            `yaml:"cluster"`
+	ControlPlane []PatchSource            `yaml:"controlPlane"`
+	Worker       []PatchSource            `yaml:"worker"`
+	Nodes        map[string][]PatchSource `yaml:"nodes"`
+}
+
+// PatchSource is a single patch. It is either a string in talosctl format
+// ("@file" or inline YAML/JSON), or a strategic merge patch or RFC 6902
+// operation list written directly as YAML in the spec.
+type PatchSource string
+
+func (p *PatchSource) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = PatchSource(value.Value)
+		return nil
+	}
+
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	*p = PatchSource(data)
+	return nil
+}
+
+type NodeSpec struct {
+	HostName     string           `yaml:"hostname"`
+	Address      string           `yaml:"address"`
+	StorageType  StorageType      `yaml:"storageType"`
+	EphemeralGB  int              `yaml:"ephemeralGB"`
+	PersistentGB int              `yaml:"persistentGB"`
+	Disks        Disks            `yaml:"disks"`
+	Volumes      []UserVolume     `yaml:"volumes"`
+	Encryption   SystemEncryption `yaml:"encryption"`
+	Interface    string           `yaml:"interface"`
+	Subnet       string           `yaml:"subnet"`
+	VIP          string           `yaml:"vip"`
+
+	Interfaces  []NetworkInterface `yaml:"interfaces"`
+	Nameservers []string           `yaml:"nameservers"`
+}
+
+// LoadSpec reads a cluster spec from path. Environment variable references
+// such as ${NODE1} are expanded before parsing so addresses can be kept out
+// of the repository.
+func LoadSpec(path string) (Spec, error) {
+	s, err := ReadSpec(path)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	return s, s.Validate()
+}
+
+// ReadSpec reads a cluster spec without validating the nodes, for commands
+// that only need cluster-wide settings.
+func ReadSpec(path string) (Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Spec{}, fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	s, err := unmarshalSpec(data)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	if s.Secrets.Path != "" && !filepath.IsAbs(s.Secrets.Path) {
+		s.Secrets.Path = filepath.Join(filepath.Dir(path), s.Secrets.Path)
+	}
+
+	s.Patches.resolve(filepath.Dir(path))
+
+	return s, nil
+}
+
+func ParseSpec(data []byte) (Spec, error) {
+	s, err := unmarshalSpec(data)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	return s, s.Validate()
+}
+
+// envReference matches ${VAR}. Bare $ is left alone so strategic merge
+// directives such as $patch in inline patches survive.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func unmarshalSpec(data []byte) (Spec, error) {
+	var s Spec
+	expanded := envReference.ReplaceAllFunc(data, func(ref []byte) []byte {
+		return []byte(os.Getenv(string(envReference.FindSubmatch(ref)[1])))
+	})
+	if err := yaml.Unmarshal(expanded, &s); err != nil {
+		return Spec{}, fmt.Errorf("failed to parse spec: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s Spec) Validate() error {
+	var err error
+	if s.Version != SpecVersion {
+		err = errors.Join(err, fmt.Errorf("unsupported spec version %q, expected %q", s.Version, SpecVersion))
+	}
+
+	err = errors.Join(err, s.Secrets.Validate())
+	err = errors.Join(err, prefixErrors("pki", s.PKI.Validate()))
+
+	for _, n := range append(append([]NodeSpec{}, s.ControlPlanes...), s.Workers...) {
+		err = errors.Join(err, prefixErrors(fmt.Sprintf("node %q", n.HostName), n.nodeConfig().Validate()))
+	}
+
+	return err
+}
+
+// Config builds a validated cluster config from the spec. The control plane
+// endpoint defaults to the shared VIP, or the first control plane's address
+// when there is none.
+func (s Spec) Config(secrets Secrets) (Config, error) {
+	endpoint := s.ControlPlaneEndpoint
+	for _, cp := range s.ControlPlanes {
+		if endpoint == "" && cp.VIP != "" {
+			endpoint = cp.VIP
+		}
+	}
+	if endpoint == "" && len(s.ControlPlanes) > 0 {
+		endpoint = s.ControlPlanes[0].Address
+	}
+
+	controlPlanes := make([]NodeConfig, 0, len(s.ControlPlanes))
+	for _, cp := range s.ControlPlanes {
+		controlPlanes = append(controlPlanes, cp.nodeConfig())
+	}
+
+	workers := make([]NodeConfig, 0, len(s.Workers))
+	for _, w := range s.Workers {
+		workers = append(workers, w.nodeConfig())
+	}
+
+	// Built directly rather than through NewConfig so the nodes are only
+	// validated against the spec's pod and service networks.
+	cfg := Config{
+		clusterName:          s.ClusterName,
+		controlPlaneEndpoint: endpoint,
+		controlPlanes:        controlPlanes,
+		workers:              workers,
+		secrets:              secrets,
+	}
+	cfg, err := cfg.WithClusterNetwork(s.ClusterNetwork)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg.WithPatches(s.Patches.patches())
+}
+
+// resolve makes "@file" patch references relative to dir.
+func (p *PatchesSpec) resolve(dir string) {
+	resolveAll := func(sources []PatchSource) {
+		for i, src := range sources {
+			if file, ok := strings.CutPrefix(string(src), "@"); ok && !filepath.IsAbs(file) {
+				sources[i] = PatchSource("@" + filepath.Join(dir, file))
+			}
+		}
+	}
+
+	resolveAll(p.Cluster)
+	resolveAll(p.ControlPlane)
+	resolveAll(p.Worker)
+	for _, sources := range p.Nodes {
+		resolveAll(sources)
+	}
+}
+
+func (p PatchesSpec) patches() Patches {
+	toStrings := func(sources []PatchSource) []string {
+		out := make([]string, 0, len(sources))
+		for _, src := range sources {
+			out = append(out, string(src))
+		}
+		return out
+	}
+
+	nodes := make(map[string][]string, len(p.Nodes))
+	for host, sources := range p.Nodes {
+		nodes[host] = toStrings(sources)
+	}
+
+	return Patches{
+		Cluster:      toStrings(p.Cluster),
+		ControlPlane: toStrings(p.ControlPlane),
+		Worker:       toStrings(p.Worker),
+		Nodes:        nodes,
+	}
+}
+
+func (n NodeSpec) nodeConfig() NodeConfig {
+	return NodeConfig{
+		HostName:     n.HostName,
+		Address:      n.Address,
+		StorageType:  n.StorageType,
+		EphemeralGB:  n.EphemeralGB,
+		PersistentGB: n.PersistentGB,
+		Disks:        n.Disks,
+		Volumes:      n.Volumes,
+		Encryption:   n.Encryption,
+		Interface:    n.Interface,
+		Subnet:       n.Subnet,
+		VIP:          n.VIP,
+		Interfaces:   n.Interfaces,
+		Nameservers:  n.Nameservers,
+	}
+}
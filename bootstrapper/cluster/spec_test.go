@@ -0,0 +1,208 @@
+package cluster_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpec(t *testing.T) {
+	t.Run("parses nodes and expands environment", func(t *testing.T) {
+		t.Setenv("TEST_CP_ADDR", "192.168.1.100")
+
+		spec, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+controlPlanes:
+  - hostname: cp1
+    address: ${TEST_CP_ADDR}
+    storageType: nvme
+    ephemeralGB: 50
+    persistentGB: 150
+workers:
+  - hostname: worker1
+    address: 192.168.1.101
+    storageType: mmc
+    ephemeralGB: 50
+    persistentGB: 300
+  - hostname: worker2
+    address: 192.168.1.102
+    storageType: mmc
+`))
+		require.NoError(t, err)
+
+		assert.Equal(t, "test-cluster", spec.ClusterName)
+		require.Len(t, spec.ControlPlanes, 1)
+		assert.Equal(t, "192.168.1.100", spec.ControlPlanes[0].Address)
+		assert.Equal(t, cluster.StorageTypeNVMe, spec.ControlPlanes[0].StorageType)
+		require.Len(t, spec.Workers, 2)
+		assert.Equal(t, 300, spec.Workers[0].PersistentGB)
+	})
+
+	t.Run("rejects unknown version", func(t *testing.T) {
+		_, err := cluster.ParseSpec([]byte(`version: v9
+clusterName: test-cluster
+`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported spec version")
+	})
+
+	t.Run("secrets path requires recipients", func(t *testing.T) {
+		t.Setenv("AGE_RECIPIENT", "")
+		_, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+secrets:
+  path: ../secrets/cluster.json.age
+`))
+		assert.ErrorContains(t, err, "secrets.path requires secrets.ageRecipients")
+
+		_, err = cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+secrets:
+  ageRecipients: ["${AGE_RECIPIENT}"]
+`))
+		assert.ErrorContains(t, err, "secrets.ageRecipients[0] is empty")
+	})
+
+	t.Run("reports invalid node by hostname", func(t *testing.T) {
+		_, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+controlPlanes:
+  - hostname: cp1
+    address: ""
+    storageType: nvme
+`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `node "cp1"`)
+		assert.Contains(t, err.Error(), "node address is required")
+	})
+
+	t.Run("endpoint defaults to first control plane", func(t *testing.T) {
+		spec, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+controlPlanes:
+  - hostname: cp1
+    address: 192.168.1.100
+    storageType: nvme
+`))
+		require.NoError(t, err)
+
+		cfg, err := spec.Config(cluster.Secrets{})
+		require.Error(t, err, "empty secrets should fail validation")
+		assert.NotContains(t, err.Error(), "control plane endpoint is required")
+		assert.NotNil(t, cfg)
+	})
+
+	t.Run("endpoint defaults to the VIP", func(t *testing.T) {
+		spec, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+controlPlanes:
+  - hostname: cp1
+    address: 192.168.50.11
+    storageType: nvme
+    ephemeralGB: 50
+    persistentGB: 150
+    interface: end0
+    subnet: 192.168.50.0/24
+    vip: 192.168.50.10
+`))
+		require.NoError(t, err)
+
+		cfg, err := spec.Config(testSecrets())
+		require.NoError(t, err)
+
+		tmpDir := t.TempDir()
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))
+		controlPlane := docs[0]["cluster"].(map[string]any)["controlPlane"].(map[string]any)
+		assert.Equal(t, "https://192.168.50.10:6443", controlPlane["endpoint"])
+	})
+
+	t.Run("nodes are checked against the spec's cluster network", func(t *testing.T) {
+		spec, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+clusterNetwork:
+  podSubnets: [10.32.0.0/16]
+  serviceSubnets: [10.48.0.0/12]
+  dnsDomain: homelab.internal
+controlPlanes:
+  - hostname: cp1
+    address: 10.96.0.11
+    storageType: nvme
+    ephemeralGB: 50
+    persistentGB: 150
+`))
+		require.NoError(t, err)
+		assert.Equal(t, "homelab.internal", spec.ClusterNetwork.DNSDomain)
+
+		_, err = spec.Config(testSecrets())
+		require.NoError(t, err)
+	})
+}
+
+func TestReadSpecPatches(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cluster.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`version: v1
+clusterName: test-cluster
+patches:
+  cluster:
+    - machine:
+        sysctls:
+          vm.nr_hugepages: "64"
+  worker:
+    - "@patches/worker.yaml"
+  nodes:
+    worker1:
+      - - op: add
+          path: /machine/nodeLabels
+          value: {}
+`), 0o644))
+
+	spec, err := cluster.ReadSpec(path)
+	require.NoError(t, err)
+
+	require.Len(t, spec.Patches.Cluster, 1)
+	assert.Contains(t, string(spec.Patches.Cluster[0]), "vm.nr_hugepages")
+	assert.Equal(t, cluster.PatchSource("@"+filepath.Join(dir, "patches/worker.yaml")), spec.Patches.Worker[0])
+	require.Len(t, spec.Patches.Nodes["worker1"], 1)
+	assert.Contains(t, string(spec.Patches.Nodes["worker1"][0]), "op: add")
+}
+
+func TestSpecInlinePatchDirectives(t *testing.T) {
+	t.Setenv("TEST_CP_ADDR", "192.168.1.100")
+
+	spec, err := cluster.ParseSpec([]byte(`version: v1
+clusterName: test-cluster
+controlPlanes:
+  - hostname: cp1
+    address: ${TEST_CP_ADDR}
+    storageType: nvme
+    ephemeralGB: 100
+    persistentGB: 200
+patches:
+  cluster:
+    - machine:
+        sysctls:
+          vm.nr_hugepages: "64"
+  controlPlane:
+    - machine:
+        sysctls:
+          $patch: delete
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.100", spec.ControlPlanes[0].Address)
+	assert.Contains(t, string(spec.Patches.ControlPlane[0]), "$patch: delete")
+
+	cfg, err := spec.Config(testSecrets())
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, cfg.GenerateConfigs(tmpDir))
+	cp := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))[0]
+	assert.NotContains(t, cp["machine"].(map[string]any), "sysctls")
+}
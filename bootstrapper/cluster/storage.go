@@ -0,0 +1,195 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/siderolabs/talos/pkg/machinery/cel"
+	"github.com/siderolabs/talos/pkg/machinery/cel/celenv"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	"gopkg.in/yaml.v3"
+)
+
+// StorageType is the transport of a node's disk, as Talos reports it in
+// disk.transport.
+type StorageType string
+
+const (
+	StorageTypeMMC  StorageType = "mmc"
+	StorageTypeNVMe StorageType = "nvme"
+	StorageTypeUSB  StorageType = "usb"
+	StorageTypeSATA StorageType = "sata"
+)
+
+var storageTypes = []StorageType{StorageTypeMMC, StorageTypeNVMe, StorageTypeUSB, StorageTypeSATA}
+
+func (s StorageType) valid() bool {
+	for _, t := range storageTypes {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
+func storageTypeNames() string {
+	names := make([]string, 0, len(storageTypes))
+	for _, t := range storageTypes {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, ", ")
+}
+
+// InstallDisk returns the fixed device path for transports that have one.
+// USB and SATA disks enumerate as /dev/sdX in no particular order, so they
+// are selected by bus path instead.
+func (s StorageType) InstallDisk() string {
+	switch s {
+	case StorageTypeMMC:
+		return "/dev/mmcblk0"
+	case StorageTypeNVMe:
+		return "/dev/nvme0n1"
+	default:
+		return ""
+	}
+}
+
+// Disks overrides which disks a node installs Talos to and places its
+// volumes on. Each selector defaults to the node's StorageType.
+type Disks struct {
+	Install   *DiskSelector `yaml:"install"`
+	Ephemeral *DiskSelector `yaml:"ephemeral"`
+	User      *DiskSelector `yaml:"user"`
+}
+
+// DiskSelector matches a disk by its properties. Model, Serial and WWID are
+// glob patterns; Size is a condition such as ">= 100GB". An empty
+// Transport uses the node's StorageType.
+type DiskSelector struct {
+	Transport StorageType `yaml:"transport"`
+	Model     string      `yaml:"model"`
+	Serial    string      `yaml:"serial"`
+	WWID      string      `yaml:"wwid"`
+	Size      string      `yaml:"size"`
+}
+
+func (d Disks) Validate() error {
+	var err error
+	for _, disk := range []struct {
+		name     string
+		selector *DiskSelector
+	}{
+		{"install disk", d.Install},
+		{"ephemeral disk", d.Ephemeral},
+		{"user disk", d.User},
+	} {
+		if disk.selector != nil {
+			err = errors.Join(err, prefixErrors(disk.name, disk.selector.Validate()))
+		}
+	}
+	return err
+}
+
+func (s DiskSelector) Validate() error {
+	var err error
+	if s.Transport != "" && !s.Transport.valid() {
+		err = errors.Join(err, fmt.Errorf("transport must be one of %s", storageTypeNames()))
+	}
+	if s.Size != "" {
+		if _, sizeErr := parseDiskSize(s.Size); sizeErr != nil {
+			err = errors.Join(err, fmt.Errorf("invalid size %q: %w", s.Size, sizeErr))
+		}
+	}
+	return err
+}
+
+// parseDiskSize parses a size condition with the same rules Talos applies
+// to install.diskSelector.size.
+func parseDiskSize(size string) (*v1alpha1.InstallDiskSizeMatcher, error) {
+	var m v1alpha1.InstallDiskSizeMatcher
+	if err := yaml.Unmarshal([]byte(strconv.Quote(size)), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// selector returns s with its transport defaulted to the node's storage
+// type.
+func (n NodeConfig) selector(s *DiskSelector) DiskSelector {
+	if s == nil {
+		return DiskSelector{Transport: n.StorageType}
+	}
+	out := *s
+	if out.Transport == "" {
+		out.Transport = n.StorageType
+	}
+	return out
+}
+
+// installConfig sets the install disk. A plain mmc or nvme node keeps the
+// fixed device path; anything else renders install.diskSelector.
+func (n NodeConfig) installConfig(install *v1alpha1.InstallConfig) error {
+	if n.Disks.Install == nil && n.StorageType.InstallDisk() != "" {
+		install.InstallDisk = n.StorageType.InstallDisk()
+		return nil
+	}
+
+	s := n.selector(n.Disks.Install)
+	selector := &v1alpha1.InstallDiskSelector{
+		Model:  s.Model,
+		Serial: s.Serial,
+		WWID:   s.WWID,
+	}
+
+	switch s.Transport {
+	case StorageTypeMMC:
+		selector.Type = "sd"
+	case StorageTypeNVMe:
+		selector.Type = "nvme"
+	case StorageTypeUSB:
+		selector.BusPath = "*/usb*"
+	case StorageTypeSATA:
+		selector.BusPath = "*/ata*"
+	}
+
+	if s.Size != "" {
+		size, err := parseDiskSize(s.Size)
+		if err != nil {
+			return fmt.Errorf("invalid install disk size: %w", err)
+		}
+		selector.Size = size
+	}
+
+	install.InstallDiskSelector = selector
+	return nil
+}
+
+// match returns the CEL expression for a volume's diskSelector.match.
+func (s DiskSelector) match() (cel.Expression, error) {
+	conditions := []string{fmt.Sprintf("disk.transport == %q", s.Transport)}
+	for _, g := range []struct{ field, pattern string }{
+		{"model", s.Model},
+		{"serial", s.Serial},
+		{"wwid", s.WWID},
+	} {
+		if g.pattern != "" {
+			conditions = append(conditions, fmt.Sprintf("glob(%q, disk.%s)", g.pattern, g.field))
+		}
+	}
+
+	if s.Size != "" {
+		size, err := parseDiskSize(s.Size)
+		if err != nil {
+			return cel.Expression{}, fmt.Errorf("invalid size: %w", err)
+		}
+		op := size.MatchData.Op
+		if op == "" {
+			op = "=="
+		}
+		conditions = append(conditions, fmt.Sprintf("disk.size %s %du", op, size.MatchData.Size))
+	}
+
+	return cel.ParseBooleanExpression(strings.Join(conditions, " && "), celenv.DiskLocator())
+}
@@ -0,0 +1,88 @@
+package cluster_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	render := func(t *testing.T, node cluster.NodeConfig) []map[string]any {
+		t.Helper()
+
+		tmpDir := t.TempDir()
+		cfg, err := cluster.NewConfig("test-cluster", node.Address, testSecrets(), []cluster.NodeConfig{node}, nil)
+		require.NoError(t, err)
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		return readDocuments(t, filepath.Join(tmpDir, "test-cluster-"+node.HostName+"-controlplane.yaml"))
+	}
+
+	volumeMatch := func(docs []map[string]any, name string) string {
+		for _, doc := range docs {
+			if doc["name"] == name {
+				return doc["provisioning"].(map[string]any)["diskSelector"].(map[string]any)["match"].(string)
+			}
+		}
+		return ""
+	}
+
+	node := func(st cluster.StorageType) cluster.NodeConfig {
+		return cluster.NodeConfig{
+			HostName:     "cp1",
+			Address:      "192.168.1.100",
+			StorageType:  st,
+			EphemeralGB:  50,
+			PersistentGB: 150,
+		}
+	}
+
+	t.Run("mmc and nvme keep their device paths", func(t *testing.T) {
+		docs := render(t, node(cluster.StorageTypeNVMe))
+		install := docs[0]["machine"].(map[string]any)["install"].(map[string]any)
+		assert.Equal(t, "/dev/nvme0n1", install["disk"])
+		assert.NotContains(t, install, "diskSelector")
+		assert.Equal(t, `disk.transport == "nvme"`, volumeMatch(docs, "EPHEMERAL"))
+		assert.Equal(t, `disk.transport == "nvme"`, volumeMatch(docs, "persistent-data"))
+	})
+
+	t.Run("usb boot selects by bus path", func(t *testing.T) {
+		docs := render(t, node(cluster.StorageTypeUSB))
+		install := docs[0]["machine"].(map[string]any)["install"].(map[string]any)
+		assert.NotContains(t, install, "disk")
+		assert.Equal(t, map[string]any{"busPath": "*/usb*"}, install["diskSelector"])
+		assert.Equal(t, `disk.transport == "usb"`, volumeMatch(docs, "EPHEMERAL"))
+	})
+
+	t.Run("sd card install with volumes on an nvme hat", func(t *testing.T) {
+		n := node(cluster.StorageTypeMMC)
+		n.Disks = cluster.Disks{
+			Install:   &cluster.DiskSelector{Serial: "0x1234*", Size: "<= 64GB"},
+			Ephemeral: &cluster.DiskSelector{Transport: cluster.StorageTypeNVMe, Model: "Samsung*"},
+			User:      &cluster.DiskSelector{Transport: cluster.StorageTypeNVMe, WWID: "eui.0025*", Size: ">= 500GB"},
+		}
+
+		docs := render(t, n)
+		install := docs[0]["machine"].(map[string]any)["install"].(map[string]any)
+		assert.Equal(t, map[string]any{"serial": "0x1234*", "size": "<= 64GB", "type": "sd"}, install["diskSelector"])
+		assert.Equal(t, `disk.transport == "nvme" && glob("Samsung*", disk.model)`, volumeMatch(docs, "EPHEMERAL"))
+		assert.Equal(t, `disk.transport == "nvme" && glob("eui.0025*", disk.wwid) && disk.size >= 500000000000u`, volumeMatch(docs, "persistent-data"))
+	})
+
+	t.Run("validates transports and sizes", func(t *testing.T) {
+		n := node("floppy")
+		n.Disks = cluster.Disks{
+			Install: &cluster.DiskSelector{Size: "big"},
+			User:    &cluster.DiskSelector{Transport: "scsi"},
+		}
+
+		err := n.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "storage type must be one of mmc, nvme, usb, sata")
+		assert.Contains(t, err.Error(), `install disk: invalid size "big"`)
+		assert.Contains(t, err.Error(), "user disk: transport must be one of mmc, nvme, usb, sata")
+	})
+}
@@ -1,9 +1,16 @@
 package cluster
 
 import (
+	"bytes"
 	"encoding/base64"
-	"os"
+	"fmt"
+	"slices"
+	"strings"
 	"text/template"
+
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	"github.com/siderolabs/talos/pkg/machinery/role"
 )
 
 const talosconfigTemplate = `context: {{.Context}}
@@ -31,28 +38,122 @@ type TalosconfigData struct {
 	Key       string
 }
 
-func (c Config) generateTalosconfig(outputPath string) error {
+// Talosconfig returns the admin talosconfig for the cluster.
+func (c Config) Talosconfig() ([]byte, error) {
+	return c.renderTalosconfig(c.clusterName, c.secrets.OSAdminCert, c.secrets.OSAdminKey)
+}
+
+// DefaultTalosconfigLifetime is the validity of role-scoped client
+// certificates unless a caller asks for something else.
+const DefaultTalosconfigLifetime = constants.TalosAPIDefaultCertificateValidityDuration
+
+// ClientRoles are the Talos API roles a scoped talosconfig can be issued for.
+var ClientRoles = []role.Role{role.Reader, role.Operator, role.EtcdBackup}
+
+// ParseClientRole accepts a role with or without the "os:" prefix, e.g.
+// "reader" or "os:etcd:backup".
+func ParseClientRole(name string) (role.Role, error) {
+	r := role.Role(name)
+	if !strings.HasPrefix(name, role.Prefix) {
+		r = role.Role(role.Prefix + name)
+	}
+
+	if !slices.Contains(ClientRoles, r) {
+		return "", fmt.Errorf("unsupported role %q, expected one of %s", name, clientRoleNames())
+	}
+
+	return r, nil
+}
+
+func clientRoleNames() string {
+	names := make([]string, 0, len(ClientRoles))
+	for _, r := range ClientRoles {
+		names = append(names, strings.TrimPrefix(string(r), role.Prefix))
+	}
+	return strings.Join(names, ", ")
+}
+
+// ClientRoleName is r without the "os:" prefix and with colons replaced,
+// suitable for file and context names, e.g. "etcd-backup".
+func ClientRoleName(r role.Role) string {
+	return strings.ReplaceAll(strings.TrimPrefix(string(r), role.Prefix), ":", "-")
+}
+
+// RoleContextName is the talosconfig context for r, e.g. "homelab-reader".
+func (c Config) RoleContextName(r role.Role) string {
+	return c.clusterName + "-" + ClientRoleName(r)
+}
+
+// RoleTalosconfig signs a client certificate for r with the OS CA,
+// following opts, and returns a talosconfig using it. Unless opts say
+// otherwise the certificate is valid for DefaultTalosconfigLifetime.
+func (c Config) RoleTalosconfig(r role.Role, opts CertOptions) ([]byte, error) {
+	if !slices.Contains(ClientRoles, r) {
+		return nil, fmt.Errorf("unsupported role %q, expected one of %s", r, clientRoleNames())
+	}
+
+	crt, key, err := generateClientCert(c.secrets.OSCert, c.secrets.OSKey, "", []string{string(r)}, opts.withDefaults(KeyEd25519, DefaultTalosconfigLifetime))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s client certificate: %w", r, err)
+	}
+
+	return c.renderTalosconfig(c.RoleContextName(r), crt, key)
+}
+
+func (c Config) renderTalosconfig(context, crt, key string) ([]byte, error) {
 	tmpl, err := template.New("talosconfig").Parse(talosconfigTemplate)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	data := TalosconfigData{
-		Context:   c.clusterName,
+		Context:   context,
 		Endpoints: c.controlPlaneAddresses(),
 		Nodes:     c.getAllNodeAddresses(),
-		CA:        base64.StdEncoding.EncodeToString([]byte(c.secrets.OSCert)),
-		Crt:       base64.StdEncoding.EncodeToString([]byte(c.secrets.OSAdminCert)),
-		Key:       base64.StdEncoding.EncodeToString([]byte(c.secrets.OSAdminKey)),
+		CA:        base64.StdEncoding.EncodeToString([]byte(c.secrets.TrustBundle(CAOS))),
+		Crt:       base64.StdEncoding.EncodeToString([]byte(crt)),
+		Key:       base64.StdEncoding.EncodeToString([]byte(key)),
 	}
 
-	file, err := os.Create(outputPath)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MergeTalosconfig adds the contexts from generated to existing, replacing
+// contexts with the same name and keeping every other one. The current
+// context only moves to the generated one when setCurrent is true or
+// existing has none.
+func MergeTalosconfig(existing, generated []byte, setCurrent bool) ([]byte, error) {
+	add, err := clientconfig.FromBytes(generated)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to parse generated talosconfig: %w", err)
+	}
+
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return generated, nil
+	}
+
+	base, err := clientconfig.FromBytes(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing talosconfig: %w", err)
+	}
+
+	if base.Contexts == nil {
+		base.Contexts = map[string]*clientconfig.Context{}
+	}
+	for name, ctx := range add.Contexts {
+		base.Contexts[name] = ctx
+	}
+
+	if setCurrent || base.Context == "" {
+		base.Context = add.Context
 	}
-	defer file.Close()
 
-	return tmpl.Execute(file, data)
+	return base.Bytes()
 }
 
 func (c Config) getAllNodeAddresses() []string {
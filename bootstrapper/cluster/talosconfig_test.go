@@ -0,0 +1,133 @@
+package cluster_test
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/siderolabs/talos/pkg/machinery/role"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRoleTalosconfig(t *testing.T) {
+	cs := generatedSecrets(t)
+
+	cp, err := cluster.NewNodeConfig("cp1", "192.168.1.100", cluster.StorageTypeNVMe, 100, 200)
+	require.NoError(t, err)
+
+	cfg, err := cluster.NewConfig("test-cluster", "192.168.1.100", cs, []cluster.NodeConfig{cp}, nil)
+	require.NoError(t, err)
+
+	for _, r := range cluster.ClientRoles {
+		t.Run(string(r), func(t *testing.T) {
+			data, err := cfg.RoleTalosconfig(r, cluster.CertOptions{Validity: 48 * time.Hour})
+			require.NoError(t, err)
+
+			var tc struct {
+				Context  string `yaml:"context"`
+				Contexts map[string]struct {
+					CA  string `yaml:"ca"`
+					Crt string `yaml:"crt"`
+				} `yaml:"contexts"`
+			}
+			require.NoError(t, yaml.Unmarshal(data, &tc))
+			assert.Equal(t, cfg.RoleContextName(r), tc.Context)
+			require.Contains(t, tc.Contexts, tc.Context)
+
+			crtPEM, err := base64.StdEncoding.DecodeString(tc.Contexts[tc.Context].Crt)
+			require.NoError(t, err)
+			block, _ := pem.Decode(crtPEM)
+			require.NotNil(t, block)
+			crt, err := x509.ParseCertificate(block.Bytes)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{string(r)}, crt.Subject.Organization)
+			assert.WithinDuration(t, time.Now().Add(48*time.Hour), crt.NotAfter, time.Minute)
+			assert.NotEqual(t, cs.OSAdminCert, string(crtPEM))
+		})
+	}
+
+	t.Run("rejects admin", func(t *testing.T) {
+		_, err := cfg.RoleTalosconfig(role.Admin, cluster.CertOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestParseClientRole(t *testing.T) {
+	for in, want := range map[string]role.Role{
+		"reader":         role.Reader,
+		"os:operator":    role.Operator,
+		"etcd:backup":    role.EtcdBackup,
+		"os:etcd:backup": role.EtcdBackup,
+	} {
+		got, err := cluster.ParseClientRole(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := cluster.ParseClientRole("admin")
+	assert.ErrorContains(t, err, "reader, operator, etcd:backup")
+}
+
+func TestMergeTalosconfig(t *testing.T) {
+	existing := []byte(`context: staging
+contexts:
+    staging:
+        endpoints:
+            - 10.0.0.1
+        ca: c3RhZ2luZw==
+    test-cluster:
+        endpoints:
+            - 10.9.9.9
+`)
+	generated := []byte(`context: test-cluster
+contexts:
+    test-cluster:
+        endpoints:
+            - 192.168.1.100
+        nodes:
+            - 192.168.1.100
+        ca: "Y2E="
+        crt: "Y3J0"
+        key: "a2V5"
+`)
+
+	type talosconfig struct {
+		Context  string `yaml:"context"`
+		Contexts map[string]struct {
+			Endpoints []string `yaml:"endpoints"`
+		} `yaml:"contexts"`
+	}
+
+	t.Run("replaces this cluster and keeps others", func(t *testing.T) {
+		merged, err := cluster.MergeTalosconfig(existing, generated, false)
+		require.NoError(t, err)
+
+		var tc talosconfig
+		require.NoError(t, yaml.Unmarshal(merged, &tc))
+		assert.Equal(t, "staging", tc.Context)
+		assert.Len(t, tc.Contexts, 2)
+		assert.Equal(t, []string{"10.0.0.1"}, tc.Contexts["staging"].Endpoints)
+		assert.Equal(t, []string{"192.168.1.100"}, tc.Contexts["test-cluster"].Endpoints)
+	})
+
+	t.Run("sets the current context on request", func(t *testing.T) {
+		merged, err := cluster.MergeTalosconfig(existing, generated, true)
+		require.NoError(t, err)
+
+		var tc talosconfig
+		require.NoError(t, yaml.Unmarshal(merged, &tc))
+		assert.Equal(t, "test-cluster", tc.Context)
+	})
+
+	t.Run("uses the generated file when nothing exists", func(t *testing.T) {
+		merged, err := cluster.MergeTalosconfig(nil, generated, false)
+		require.NoError(t, err)
+		assert.Equal(t, generated, merged)
+	})
+}
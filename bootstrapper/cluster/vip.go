@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// vip returns the shared control plane address, or "" when the cluster
+// does not use one.
+func (c Config) vip() string {
+	for _, cp := range c.controlPlanes {
+		if cp.VIP != "" {
+			return cp.VIP
+		}
+	}
+	return ""
+}
+
+// validateVIP checks that every control plane floats the same VIP on a
+// named interface, that it is inside each control plane's subnet and that
+// no node already holds it, as its address or a static interface or VLAN
+// address.
+func (c Config) validateVIP() error {
+	vip := c.vip()
+	if vip == "" {
+		return nil
+	}
+
+	var err error
+	addr, parseErr := netip.ParseAddr(vip)
+	if parseErr != nil {
+		return fmt.Errorf("invalid VIP %q: %w", vip, parseErr)
+	}
+
+	for _, cp := range c.controlPlanes {
+		if cp.VIP != vip {
+			err = errors.Join(err, fmt.Errorf("control plane %s: VIP %q differs from %q", cp.HostName, cp.VIP, vip))
+			continue
+		}
+		if cp.Interface == "" {
+			err = errors.Join(err, fmt.Errorf("control plane %s: interface is required for the VIP", cp.HostName))
+		} else if cp.vipLinkUndeclared() {
+			err = errors.Join(err, fmt.Errorf("control plane %s: VIP interface %s must be declared by name when links use device selectors", cp.HostName, cp.Interface))
+		}
+
+		subnets := cp.subnets()
+		if len(subnets) == 0 {
+			err = errors.Join(err, fmt.Errorf("control plane %s: a subnet or static address is required for the VIP", cp.HostName))
+			continue
+		}
+		if !containsAddr(subnets, addr) {
+			err = errors.Join(err, fmt.Errorf("control plane %s: VIP %s is outside subnet %s", cp.HostName, vip, strings.Join(subnets, ", ")))
+		}
+	}
+
+	for _, w := range c.workers {
+		if w.VIP != "" {
+			err = errors.Join(err, fmt.Errorf("worker %s: only control planes can hold the VIP", w.HostName))
+		}
+	}
+
+	if c.hasAddress(addr) {
+		err = errors.Join(err, fmt.Errorf("VIP %s is already assigned to a node", vip))
+	}
+
+	return err
+}
+
+// hasAddress reports whether any node has addr as its address or as a
+// static interface or VLAN address. Addresses are compared parsed, so
+// different spellings of the same address match.
+func (c Config) hasAddress(addr netip.Addr) bool {
+	for _, n := range c.nodes() {
+		if a, err := netip.ParseAddr(n.Address); err == nil && a.Unmap() == addr.Unmap() {
+			return true
+		}
+		for _, a := range n.staticAddresses() {
+			if a.Unmap() == addr.Unmap() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vipLinkUndeclared reports whether the VIP interface is not declared by
+// name while other links are matched by device selector. The VIP link may
+// then be one of the selector links, and adding it by name would configure
+// the same link twice.
+func (n NodeConfig) vipLinkUndeclared() bool {
+	selectors := false
+	for _, iface := range n.Interfaces {
+		if iface.Name == n.Interface {
+			return false
+		}
+		selectors = selectors || iface.Selector != nil
+	}
+	return selectors
+}
+
+func containsAddr(subnets []string, addr netip.Addr) bool {
+	for _, s := range subnets {
+		if p, err := netip.ParsePrefix(s); err == nil && p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// certSANs are the names the API server and apid certificates must cover:
+// the shared endpoint and VIP first, then every control plane address.
+func (c Config) certSANs() []string {
+	sans := []string{c.controlPlaneEndpoint}
+	for _, addr := range append([]string{c.vip()}, c.controlPlaneAddresses()...) {
+		if addr == "" {
+			continue
+		}
+		if !slices.Contains(sans, addr) {
+			sans = append(sans, addr)
+		}
+	}
+	return sans
+}
@@ -0,0 +1,157 @@
+package cluster_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func vipNode(hostname, address, vip string) cluster.NodeConfig {
+	return cluster.NodeConfig{
+		HostName:     hostname,
+		Address:      address,
+		StorageType:  cluster.StorageTypeNVMe,
+		EphemeralGB:  50,
+		PersistentGB: 150,
+		Interface:    "end0",
+		Subnet:       "192.168.50.0/24",
+		VIP:          vip,
+	}
+}
+
+func TestVIPValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		cps      []cluster.NodeConfig
+		workers  []cluster.NodeConfig
+		errorMsg string
+	}{
+		{
+			name: "valid",
+			cps: []cluster.NodeConfig{
+				vipNode("cp1", "192.168.50.11", "192.168.50.10"),
+				vipNode("cp2", "192.168.50.12", "192.168.50.10"),
+			},
+		},
+		{
+			name:     "outside subnet",
+			cps:      []cluster.NodeConfig{vipNode("cp1", "192.168.50.11", "10.0.0.10")},
+			errorMsg: "control plane cp1: VIP 10.0.0.10 is outside subnet 192.168.50.0/24",
+		},
+		{
+			name:     "assigned to a node",
+			cps:      []cluster.NodeConfig{vipNode("cp1", "192.168.50.11", "192.168.50.12")},
+			workers:  []cluster.NodeConfig{vipNode("worker1", "192.168.50.12", "")},
+			errorMsg: "VIP 192.168.50.12 is already assigned to a node",
+		},
+		{
+			name: "assigned to a static interface",
+			cps: []cluster.NodeConfig{func() cluster.NodeConfig {
+				n := vipNode("cp1", "192.168.50.11", "192.168.50.10")
+				n.Interfaces = []cluster.NetworkInterface{{Name: "end0", Addresses: []string{"192.168.50.11/24", "192.168.50.10/24"}}}
+				return n
+			}()},
+			errorMsg: "VIP 192.168.50.10 is already assigned to a node",
+		},
+		{
+			name: "assigned to a VLAN",
+			cps:  []cluster.NodeConfig{vipNode("cp1", "192.168.50.11", "192.168.50.10")},
+			workers: []cluster.NodeConfig{func() cluster.NodeConfig {
+				n := vipNode("worker1", "192.168.50.14", "")
+				n.Interfaces = []cluster.NetworkInterface{{
+					Name:      "end0",
+					Addresses: []string{"192.168.50.14/24"},
+					VLANs:     []cluster.VLAN{{ID: 20, Addresses: []string{"192.168.50.10/24"}}},
+				}}
+				return n
+			}()},
+			errorMsg: "VIP 192.168.50.10 is already assigned to a node",
+		},
+		{
+			name: "assigned to a node in another spelling",
+			cps: []cluster.NodeConfig{func() cluster.NodeConfig {
+				n := vipNode("cp1", "fd00::11", "fd00::a")
+				n.Subnet = "fd00::/64"
+				return n
+			}()},
+			workers: []cluster.NodeConfig{func() cluster.NodeConfig {
+				n := vipNode("worker1", "fd00:0:0:0:0:0:0:a", "")
+				n.Subnet = "fd00::/64"
+				return n
+			}()},
+			errorMsg: "VIP fd00::a is already assigned to a node",
+		},
+		{
+			name: "link declared by device selector",
+			cps: []cluster.NodeConfig{func() cluster.NodeConfig {
+				n := vipNode("cp1", "192.168.50.11", "192.168.50.10")
+				n.Interfaces = []cluster.NetworkInterface{{
+					Selector:  &cluster.DeviceSelector{HardwareAddr: "2c:cf:67:*"},
+					Addresses: []string{"192.168.50.11/24"},
+				}}
+				return n
+			}()},
+			errorMsg: "control plane cp1: VIP interface end0 must be declared by name when links use device selectors",
+		},
+		{
+			name: "differs between control planes",
+			cps: []cluster.NodeConfig{
+				vipNode("cp1", "192.168.50.11", "192.168.50.10"),
+				vipNode("cp2", "192.168.50.12", "192.168.50.20"),
+			},
+			errorMsg: `control plane cp2: VIP "192.168.50.20" differs from "192.168.50.10"`,
+		},
+		{
+			name:     "held by a worker",
+			cps:      []cluster.NodeConfig{vipNode("cp1", "192.168.50.11", "192.168.50.10")},
+			workers:  []cluster.NodeConfig{vipNode("worker1", "192.168.50.14", "192.168.50.10")},
+			errorMsg: "worker worker1: only control planes can hold the VIP",
+		},
+		{
+			name:     "not an IP",
+			cps:      []cluster.NodeConfig{vipNode("cp1", "192.168.50.11", "vip.local")},
+			errorMsg: `invalid VIP "vip.local"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cluster.NewConfig("test-cluster", "192.168.50.10", testSecrets(), tt.cps, tt.workers)
+			if tt.errorMsg == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errorMsg)
+		})
+	}
+}
+
+func TestVIPRendering(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg, err := cluster.NewConfig("test-cluster", "k8s.example.lan", testSecrets(), []cluster.NodeConfig{
+		vipNode("cp1", "192.168.50.11", "192.168.50.10"),
+		vipNode("cp2", "192.168.50.12", "192.168.50.10"),
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+	docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))
+	machine := docs[0]["machine"].(map[string]any)
+
+	interfaces := machine["network"].(map[string]any)["interfaces"].([]any)
+	require.Len(t, interfaces, 1)
+	iface := interfaces[0].(map[string]any)
+	assert.Equal(t, "end0", iface["interface"])
+	assert.Equal(t, map[string]any{"ip": "192.168.50.10"}, iface["vip"])
+
+	wantSANs := []any{"k8s.example.lan", "192.168.50.10", "192.168.50.11", "192.168.50.12"}
+	assert.Equal(t, wantSANs, machine["certSANs"])
+
+	clusterDoc := docs[0]["cluster"].(map[string]any)
+	assert.Equal(t, wantSANs, clusterDoc["apiServer"].(map[string]any)["certSANs"])
+	assert.Equal(t, "https://k8s.example.lan:6443", clusterDoc["controlPlane"].(map[string]any)["endpoint"])
+}
@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/siderolabs/go-pointer"
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/block"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	blockres "github.com/siderolabs/talos/pkg/machinery/resources/block"
+)
+
+const (
+	longhornCreateDefaultDiskLabel = "node.longhorn.io/create-default-disk"
+	longhornDefaultDisksAnnotation = "node.longhorn.io/default-disks-config"
+	maxUserVolumeNameLength        = 34
+)
+
+// UserVolume is a named volume Talos creates and mounts at /var/mnt/<name>.
+// MinGB or MaxGB is required; Grow lets the volume take the rest of the
+// disk, capped at MaxGB when set.
+type UserVolume struct {
+	Name  string `yaml:"name"`
+	MinGB int    `yaml:"minGB"`
+	MaxGB int    `yaml:"maxGB"`
+	Grow  bool   `yaml:"grow"`
+	// Filesystem is xfs (the default) or ext4.
+	Filesystem string `yaml:"filesystem"`
+	// Disk picks the disk the volume lives on, defaulting to the node's
+	// user disk.
+	Disk       *DiskSelector     `yaml:"disk"`
+	Encryption *VolumeEncryption `yaml:"encryption"`
+	// Longhorn registers the volume as a Longhorn disk on the node.
+	Longhorn *LonghornDisk `yaml:"longhorn"`
+}
+
+// LonghornDisk is the Longhorn disk created for a volume. Tags let
+// storage classes target specific disks, e.g. nvme.
+type LonghornDisk struct {
+	Tags            []string `yaml:"tags"`
+	StorageReserved int      `yaml:"storageReservedGB"`
+}
+
+func (v UserVolume) Validate() error {
+	var err error
+	if v.Name == "" || len(v.Name) > maxUserVolumeNameLength {
+		err = errors.Join(err, fmt.Errorf("name must be between 1 and %d characters long", maxUserVolumeNameLength))
+	} else if !validLabel(v.Name) {
+		err = errors.Join(err, errors.New("name can only contain letters, digits and inner hyphens"))
+	}
+
+	switch {
+	case v.MinGB < 0 || v.MaxGB < 0:
+		err = errors.Join(err, errors.New("sizes cannot be negative"))
+	case v.MinGB == 0 && v.MaxGB == 0:
+		err = errors.Join(err, errors.New("minGB or maxGB is required"))
+	case v.MaxGB > 0 && v.MaxGB < v.MinGB:
+		err = errors.Join(err, fmt.Errorf("maxGB %d is less than minGB %d", v.MaxGB, v.MinGB))
+	}
+
+	if _, fsErr := v.filesystemType(); fsErr != nil {
+		err = errors.Join(err, fsErr)
+	}
+
+	if v.Disk != nil {
+		err = errors.Join(err, prefixErrors("disk", v.Disk.Validate()))
+	}
+
+	if v.Encryption != nil {
+		err = errors.Join(err, prefixErrors("encryption", v.Encryption.Validate()))
+	}
+
+	if v.Longhorn != nil && v.Longhorn.StorageReserved < 0 {
+		err = errors.Join(err, errors.New("longhorn storageReservedGB cannot be negative"))
+	}
+
+	return err
+}
+
+func (v UserVolume) filesystemType() (blockres.FilesystemType, error) {
+	switch v.Filesystem {
+	case "", "xfs":
+		return blockres.FilesystemTypeXFS, nil
+	case "ext4":
+		return blockres.FilesystemTypeEXT4, nil
+	default:
+		return 0, fmt.Errorf("unsupported filesystem %q, must be xfs or ext4", v.Filesystem)
+	}
+}
+
+func (v UserVolume) mountPath() string {
+	return path.Join(constants.UserVolumeMountPoint, v.Name)
+}
+
+// userVolumes returns the node's declared volumes, or the single
+// persistent-data volume sized by PersistentGB. Control planes let it grow
+// to fill the disk, workers pin it to the requested size. Longhorn's
+// default data path points at it, so it is registered as a Longhorn disk.
+func (n NodeConfig) userVolumes(grow bool) []UserVolume {
+	if len(n.Volumes) > 0 {
+		return n.Volumes
+	}
+
+	v := UserVolume{
+		Name:     persistentVolume,
+		MinGB:    n.PersistentGB,
+		Grow:     grow,
+		Longhorn: &LonghornDisk{},
+	}
+	if !grow {
+		v.MaxGB = n.PersistentGB
+	}
+
+	return []UserVolume{v}
+}
+
+// volumeDocuments returns the EPHEMERAL volume and the user volumes for a
+// node.
+func (c Config) volumeDocuments(node NodeConfig, grow bool) ([]config.Document, error) {
+	ephemeralSelector, err := node.selector(node.Disks.Ephemeral).match()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral disk selector: %w", err)
+	}
+
+	ephemeral := block.NewVolumeConfigV1Alpha1()
+	ephemeral.MetaName = constants.EphemeralPartitionLabel
+	ephemeral.ProvisioningSpec = block.ProvisioningSpec{
+		DiskSelectorSpec:    block.DiskSelector{Match: ephemeralSelector},
+		ProvisioningMinSize: gibibytes(node.EphemeralGB),
+		ProvisioningMaxSize: gibibytes(node.EphemeralGB),
+	}
+	ephemeral.EncryptionSpec = c.blockEncryption(node.HostName, constants.EphemeralPartitionLabel, node.Encryption.Ephemeral)
+
+	docs := []config.Document{ephemeral}
+	for _, v := range node.userVolumes(grow) {
+		doc, vErr := c.userVolumeDocument(node, v)
+		if vErr != nil {
+			return nil, fmt.Errorf("volume %s: %w", v.Name, vErr)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+func (c Config) userVolumeDocument(node NodeConfig, v UserVolume) (config.Document, error) {
+	disk := node.Disks.User
+	if v.Disk != nil {
+		disk = v.Disk
+	}
+
+	selector, err := node.selector(disk).match()
+	if err != nil {
+		return nil, fmt.Errorf("invalid disk selector: %w", err)
+	}
+
+	fsType, err := v.filesystemType()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := block.NewUserVolumeConfigV1Alpha1()
+	doc.MetaName = v.Name
+	doc.ProvisioningSpec = block.ProvisioningSpec{
+		DiskSelectorSpec:    block.DiskSelector{Match: selector},
+		ProvisioningMinSize: gibibytes(v.MinGB),
+		ProvisioningMaxSize: gibibytes(v.MaxGB),
+	}
+	if v.Grow {
+		doc.ProvisioningSpec.ProvisioningGrow = pointer.To(true)
+	}
+	if v.Filesystem != "" {
+		doc.FilesystemSpec.FilesystemType = fsType
+	}
+	doc.EncryptionSpec = c.blockEncryption(node.HostName, v.Name, v.Encryption)
+
+	return doc, nil
+}
+
+// longhornDisk is an entry of Longhorn's default-disks-config annotation.
+type longhornDisk struct {
+	Name            string   `json:"name"`
+	Path            string   `json:"path"`
+	AllowScheduling bool     `json:"allowScheduling"`
+	StorageReserved int64    `json:"storageReserved,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// longhornMetadata returns the node label and annotation that make Longhorn
+// create a disk for every volume marked for it, when the chart's
+// createDefaultDiskLabeledNodes setting is on. Longhorn only reads them
+// when a node first registers.
+func (n NodeConfig) longhornMetadata(grow bool) (labels, annotations map[string]string, err error) {
+	var disks []longhornDisk
+	for _, v := range n.userVolumes(grow) {
+		if v.Longhorn == nil {
+			continue
+		}
+		disks = append(disks, longhornDisk{
+			Name:            v.Name,
+			Path:            v.mountPath(),
+			AllowScheduling: true,
+			StorageReserved: int64(v.Longhorn.StorageReserved) << 30,
+			Tags:            v.Longhorn.Tags,
+		})
+	}
+
+	if len(disks) == 0 {
+		return nil, nil, nil
+	}
+
+	data, err := json.Marshal(disks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode longhorn disks: %w", err)
+	}
+
+	return map[string]string{longhornCreateDefaultDiskLabel: "config"},
+		map[string]string{longhornDefaultDisksAnnotation: string(data)},
+		nil
+}
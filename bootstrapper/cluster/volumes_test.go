@@ -0,0 +1,122 @@
+package cluster_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserVolumes(t *testing.T) {
+	longhornDisks := func(t *testing.T, machine map[string]any) []map[string]any {
+		t.Helper()
+
+		assert.Equal(t, "config", machine["nodeLabels"].(map[string]any)["node.longhorn.io/create-default-disk"])
+		annotation := machine["nodeAnnotations"].(map[string]any)["node.longhorn.io/default-disks-config"].(string)
+
+		var disks []map[string]any
+		require.NoError(t, json.Unmarshal([]byte(annotation), &disks))
+		return disks
+	}
+
+	t.Run("default persistent-data volume is a longhorn disk", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		require.NoError(t, patchTestConfig(t).GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-cp1-controlplane.yaml"))
+		machine := docs[0]["machine"].(map[string]any)
+		assert.Contains(t, machine["nodeLabels"], "node.kubernetes.io/exclude-from-external-load-balancers")
+		assert.Equal(t, []map[string]any{{
+			"name":            "persistent-data",
+			"path":            "/var/mnt/persistent-data",
+			"allowScheduling": true,
+		}}, longhornDisks(t, machine))
+
+		require.Len(t, docs, 3)
+		assert.Equal(t, "persistent-data", docs[2]["name"])
+	})
+
+	t.Run("renders named volumes", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		worker, err := cluster.NewNodeConfig("worker1", "192.168.1.101", cluster.StorageTypeMMC, 50, 0)
+		require.NoError(t, err)
+		worker.Volumes = []cluster.UserVolume{
+			{
+				Name:       "longhorn-nvme",
+				MinGB:      100,
+				Grow:       true,
+				Disk:       &cluster.DiskSelector{Transport: cluster.StorageTypeNVMe},
+				Encryption: &cluster.VolumeEncryption{Provider: "nodeID"},
+				Longhorn:   &cluster.LonghornDisk{Tags: []string{"nvme"}, StorageReserved: 10},
+			},
+			{Name: "scratch", MinGB: 20, MaxGB: 20, Filesystem: "ext4"},
+		}
+
+		cp, err := cluster.NewNodeConfig("cp1", "192.168.1.100", cluster.StorageTypeNVMe, 100, 200)
+		require.NoError(t, err)
+
+		cfg, err := cluster.NewConfig("test-cluster", "192.168.1.100", testSecrets(), []cluster.NodeConfig{cp}, []cluster.NodeConfig{worker})
+		require.NoError(t, err)
+		require.NoError(t, cfg.GenerateConfigs(tmpDir))
+
+		docs := readDocuments(t, filepath.Join(tmpDir, "test-cluster-worker1-worker.yaml"))
+		require.Len(t, docs, 4)
+
+		nvme := docs[2]
+		assert.Equal(t, "longhorn-nvme", nvme["name"])
+		assert.Equal(t, map[string]any{
+			"diskSelector": map[string]any{"match": `disk.transport == "nvme"`},
+			"minSize":      "100GiB",
+			"grow":         true,
+		}, nvme["provisioning"])
+		encryption := nvme["encryption"].(map[string]any)
+		assert.Equal(t, "luks2", encryption["provider"])
+		assert.Equal(t, []any{map[string]any{"slot": 0, "nodeID": map[string]any{}}}, encryption["keys"])
+
+		scratch := docs[3]
+		assert.Equal(t, "scratch", scratch["name"])
+		assert.Equal(t, map[string]any{"type": "ext4"}, scratch["filesystem"])
+		assert.Equal(t, `disk.transport == "mmc"`, scratch["provisioning"].(map[string]any)["diskSelector"].(map[string]any)["match"])
+
+		assert.Equal(t, []map[string]any{{
+			"name":            "longhorn-nvme",
+			"path":            "/var/mnt/longhorn-nvme",
+			"allowScheduling": true,
+			"storageReserved": float64(10 << 30),
+			"tags":            []any{"nvme"},
+		}}, longhornDisks(t, docs[0]["machine"].(map[string]any)))
+	})
+
+	t.Run("validates volumes", func(t *testing.T) {
+		node := cluster.NodeConfig{
+			HostName:     "worker1",
+			Address:      "192.168.1.101",
+			StorageType:  cluster.StorageTypeMMC,
+			PersistentGB: 100,
+			Volumes: []cluster.UserVolume{
+				{Name: "data", MinGB: 50, MaxGB: 10},
+				{Name: "data", MinGB: 10, Filesystem: "btrfs"},
+				{Name: "bad_name"},
+				{Name: "secret", MinGB: 10, Encryption: &cluster.VolumeEncryption{Provider: "tpm"}},
+			},
+		}
+
+		err := node.Validate()
+		require.Error(t, err)
+		for _, want := range []string{
+			"persistentGB and volumes are mutually exclusive",
+			"volume data: maxGB 10 is less than minGB 50",
+			`volume data: unsupported filesystem "btrfs"`,
+			`duplicate volume "data"`,
+			"volume bad_name: name can only contain letters, digits and inner hyphens",
+			"volume bad_name: minGB or maxGB is required",
+			`volume secret: encryption: unsupported encryption provider "tpm"`,
+		} {
+			assert.Contains(t, err.Error(), want)
+		}
+	})
+}
@@ -1,121 +1,52 @@
 package cluster
 
 import (
-	"encoding/base64"
-	"os"
-	"text/template"
+	"github.com/siderolabs/go-pointer"
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
 )
 
-const workerTemplate = `version: v1alpha1
-debug: false
-persist: true
-machine:
-  type: worker
-  token: {{.Token}}
-  ca:
-    crt: "{{.OSCert}}"
-    key: ""
-  certSANs: []
-  kubelet:
-    image: ghcr.io/siderolabs/kubelet:v1.34.0
-    defaultRuntimeSeccompProfileEnabled: true
-    disableManifestsDirectory: true
-    nodeIP:
-      validSubnets:
-        - 192.168.50.0/24
-  network:
-    hostname: {{.HostName}}
-  time:
-    servers:
-      - time.cloudflare.com
-  install:
-    disk: {{.InstallDisk}}
-    image: ghcr.io/failuretoload/talos-rpi5-v1.11.5-1-custom:latest
-    wipe: false
-  features:
-    rbac: true
-    stableHostname: true
-    apidCheckExtKeyUsage: true
-    diskQuotaSupport: true
-    kubePrism:
-      enabled: true
-      port: 7445
-    hostDNS:
-      enabled: true
-      forwardKubeDNSToHost: true
-cluster:
-  id: {{.ClusterID}}
-  secret: {{.ClusterSecret}}
-  controlPlane:
-    endpoint: https://{{.ControlPlaneAddress}}:6443
-  clusterName: {{.ClusterName}}
-  network:
-    cni:
-      name: none
-    dnsDomain: cluster.local
-    podSubnets:
-      - 10.244.0.0/16
-    serviceSubnets:
-      - 10.96.0.0/12
-  token: {{.BootstrapToken}}
-  ca:
-    crt: "{{.K8SCert}}"
-    key: ""
-  proxy:
-    disabled: true
-  discovery:
-    enabled: true
-    registries:
-      kubernetes:
-        disabled: true
-      service: {}
----
-apiVersion: v1alpha1
-kind: VolumeConfig
-name: EPHEMERAL
-provisioning:
-  diskSelector:
-    match: disk.transport == "{{.StorageType}}"
-  minSize: {{.Ephemeral}}GiB
-  maxSize: {{.Ephemeral}}GiB
----
-apiVersion: v1alpha1
-kind: UserVolumeConfig
-name: persistent-data
-provisioning:
-  diskSelector:
-    match: disk.transport == "{{.StorageType}}"
-  minSize: {{.Persistent}}GiB
-  maxSize: {{.Persistent}}GiB
-`
+func (c Config) generateWorkerYAML(worker NodeConfig) ([]byte, error) {
+	docs, err := c.workerDocuments(worker)
+	if err != nil {
+		return nil, err
+	}
+
+	return renderDocuments(docs, c.patchesFor(machine.TypeWorker, worker.HostName))
+}
+
+func (c Config) workerDocuments(worker NodeConfig) ([]config.Document, error) {
+	machineConfig, err := c.baseMachineConfig(machine.TypeWorker, worker)
+	if err != nil {
+		return nil, err
+	}
+
+	machineConfig.MachineCA = certAndKey(c.secrets.OSCert, "")
+	machineConfig.MachineAcceptedCAs = c.secrets.acceptedCACerts(CAOS)
+	machineConfig.MachineCertSANs = []string{}
 
-func (c Config) generateWorkerYAML(outPath string, worker NodeConfig, controlPlaneAddress string) error {
-	tmpl, err := template.New("worker").Parse(workerTemplate)
+	clusterConfig, err := c.baseClusterConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	clusterConfig.ClusterCA = certAndKey(c.secrets.K8SCert, "")
+	clusterConfig.ClusterAcceptedCAs = c.secrets.acceptedCACerts(CAKubernetes)
 
-	f, err := os.Create(outPath)
+	volumes, err := c.volumeDocuments(worker, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
 
-	data := map[string]any{
-		"Token":               c.secrets.Token,
-		"OSCert":              base64.StdEncoding.EncodeToString([]byte(c.secrets.OSCert)),
-		"HostName":            worker.HostName,
-		"StorageType":         worker.StorageType,
-		"InstallDisk":         worker.StorageType.InstallDisk(),
-		"ClusterID":           c.secrets.ClusterID,
-		"ClusterSecret":       c.secrets.ClusterSecret,
-		"ControlPlaneAddress": controlPlaneAddress,
-		"ClusterName":         c.clusterName,
-		"BootstrapToken":      c.secrets.BootstrapToken,
-		"K8SCert":             base64.StdEncoding.EncodeToString([]byte(c.secrets.K8SCert)),
-		"Ephemeral":           worker.EphemeralGB,
-		"Persistent":          worker.PersistentGB,
+	docs := []config.Document{
+		&v1alpha1.Config{
+			ConfigVersion: "v1alpha1",
+			ConfigDebug:   pointer.To(false),
+			ConfigPersist: pointer.To(true),
+			MachineConfig: machineConfig,
+			ClusterConfig: clusterConfig,
+		},
 	}
 
-	return tmpl.Execute(f, data)
+	return append(docs, volumes...), nil
 }
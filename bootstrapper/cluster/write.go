@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// outputMode is used for every generated file. Machine configs carry CA keys
+// and the talosconfig carries the admin client key.
+const outputMode = 0o600
+
+// WriteFile atomically replaces path with data, readable only by the owner.
+// Readers see either the old file or the new one, never a partial write.
+func WriteFile(path string, data []byte) error {
+	return WriteFiles(filepath.Dir(path), []RenderedFile{{Name: filepath.Base(path), Data: data}})
+}
+
+// WriteFiles stages every file next to its destination in dir and only
+// renames them into place once all of them have been written and synced.
+func WriteFiles(dir string, files []RenderedFile) error {
+	staged := make([]string, 0, len(files))
+	cleanup := func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}
+
+	for _, f := range files {
+		tmp, err := stageFile(filepath.Join(dir, f.Name), f.Data)
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("failed to write %s: %w", f.Name, err)
+		}
+		staged = append(staged, tmp)
+	}
+
+	for i, f := range files {
+		if err := os.Rename(staged[i], filepath.Join(dir, f.Name)); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to replace %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func stageFile(path string, data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	if err = f.Chmod(outputMode); err == nil {
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+	}
+	err = errors.Join(err, f.Close())
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
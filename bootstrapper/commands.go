@@ -0,0 +1,660 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+)
+
+func rootCommand() *command {
+	return &command{
+		name: "bootstrapper",
+		subcommands: []*command{
+			{
+				name:    "generate",
+				summary: "render machine configs and talosconfig",
+				flags: func(fs *flag.FlagSet, o *options) {
+					fs.BoolVar(&o.newSecrets, "new-secrets", false, "generate fresh cluster secrets instead of loading the existing file")
+					fs.BoolVar(&o.diff, "diff", false, "show what would change in the output directory without writing; exits 3 if anything changed")
+					talosconfigMergeFlags(fs, o)
+				},
+				run: runGenerate,
+			},
+			{
+				name:    "secrets",
+				summary: "manage cluster secrets",
+				subcommands: []*command{
+					{
+						name:    "init",
+						summary: "create a new secrets file",
+						flags: func(fs *flag.FlagSet, o *options) {
+							fs.BoolVar(&o.force, "force", false, "overwrite an existing secrets file")
+						},
+						run: runSecretsInit,
+					},
+					{
+						name:    "show",
+						summary: "print the secrets file",
+						flags: func(fs *flag.FlagSet, o *options) {
+							fs.BoolVar(&o.reveal, "reveal", false, "print secret values instead of redacting them")
+						},
+						run: runSecretsShow,
+					},
+					{
+						name:    "import",
+						summary: "create the secrets file from a talosctl secrets.yaml",
+						flags: func(fs *flag.FlagSet, o *options) {
+							fs.BoolVar(&o.force, "force", false, "overwrite an existing secrets file")
+						},
+						run: runSecretsImport,
+					},
+					{
+						name:    "export",
+						summary: "write the secrets as a talosctl secrets.yaml",
+						run:     runSecretsExport,
+					},
+					{
+						name:    "rotate",
+						summary: "replace all secrets but volume keys and the homelab PKI, backing up the old file",
+						run:     runSecretsRotate,
+					},
+				},
+			},
+			{
+				name:    "validate",
+				summary: "check the spec and secrets without writing anything",
+				run:     runValidate,
+			},
+			{
+				name:    "diff",
+				summary: "compare rendered configs with the output directory",
+				run:     runDiff,
+			},
+			{
+				name:    "talosconfig",
+				summary: "write only the talosconfig, or a role-scoped one with -role",
+				flags: func(fs *flag.FlagSet, o *options) {
+					fs.StringVar(&o.role, "role", "", "issue a client certificate for reader, operator or etcd:backup instead of using the admin one")
+					fs.DurationVar(&o.lifetime, "lifetime", 0, "validity of the role client certificate (default secrets.certs.talosClient.validity, else 1 year)")
+					talosconfigMergeFlags(fs, o)
+				},
+				run: runTalosconfig,
+			},
+			{
+				name:    "kubeconfig",
+				summary: "write an admin kubeconfig signed by the cluster CA",
+				flags: func(fs *flag.FlagSet, o *options) {
+					fs.DurationVar(&o.lifetime, "lifetime", 0, "validity of the admin client certificate (default secrets.certs.kubeconfig.validity, else 1 year)")
+					fs.BoolVar(&o.merge, "merge", false, "merge into the kubeconfig at -kubeconfig instead of writing to the output directory")
+					fs.StringVar(&o.kubeconfigPath, "kubeconfig", filepath.Join(os.Getenv("HOME"), ".kube", "config"), "kubeconfig to merge into")
+				},
+				run: runKubeconfig,
+			},
+			{
+				name:    "certs",
+				summary: "inspect the cluster PKI",
+				subcommands: []*command{
+					{
+						name:    "status",
+						summary: "list certificates and their expiry; exits 4 if any expire within -threshold days",
+						flags: func(fs *flag.FlagSet, o *options) {
+							fs.IntVar(&o.thresholdDays, "threshold", 30, "days before expiry at which to fail")
+						},
+						run: runCertsStatus,
+					},
+					{
+						name:    "renew",
+						summary: "re-sign the hubble and/or admin certificates from their existing CAs",
+						flags: func(fs *flag.FlagSet, o *options) {
+							fs.DurationVar(&o.lifetime, "lifetime", 0, "validity of the renewed admin client certificate (default secrets.certs.talosClient.validity, else 1 year)")
+							talosconfigMergeFlags(fs, o)
+						},
+						run: runCertsRenew,
+					},
+					{
+						name:    "rotate",
+						summary: "rotate CAs in three steps, applying the configs to every node between them",
+						subcommands: []*command{
+							{
+								name:    "begin",
+								summary: "generate new CAs and trust them next to the current ones",
+								flags: func(fs *flag.FlagSet, o *options) {
+									fs.StringVar(&o.cas, "ca", "os,kubernetes,etcd,cilium", "comma-separated CAs to rotate")
+									talosconfigMergeFlags(fs, o)
+								},
+								run: runRotateBegin,
+							},
+							{
+								name:    "switch",
+								summary: "issue from the new CAs while still trusting the old ones",
+								flags: func(fs *flag.FlagSet, o *options) {
+									fs.DurationVar(&o.lifetime, "lifetime", 0, "validity of the re-signed admin client certificate (default secrets.certs.talosClient.validity, else 1 year)")
+									talosconfigMergeFlags(fs, o)
+								},
+								run: runRotateSwitch,
+							},
+							{
+								name:    "finish",
+								summary: "stop trusting the old CAs",
+								flags:   talosconfigMergeFlags,
+								run:     runRotateFinish,
+							},
+						},
+					},
+				},
+			},
+			{
+				name:    "pki",
+				summary: "manage the offline homelab root CA and its intermediates",
+				subcommands: []*command{
+					{
+						name:    "issue",
+						summary: "issue missing intermediates, or re-sign the named ones with -renew, and write their Secret manifests",
+						flags: func(fs *flag.FlagSet, o *options) {
+							fs.BoolVar(&o.renew, "renew", false, "re-sign the named intermediates, or all of them when none are named")
+						},
+						run: runPKIIssue,
+					},
+				},
+			},
+			{
+				name:    "backup",
+				summary: "manage config backups",
+				subcommands: []*command{
+					{
+						name:    "list",
+						summary: "list backups in the output directory",
+						run:     runBackupList,
+					},
+					{
+						name:    "restore",
+						summary: "restore a backup by name",
+						run:     runBackupRestore,
+					},
+				},
+			},
+		},
+	}
+}
+
+func talosconfigMergeFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.merge, "merge", false, "merge this cluster's context into the existing talosconfig instead of replacing the file")
+	fs.BoolVar(&o.setCurrent, "set-current", false, "make this cluster the current talosconfig context when merging")
+}
+
+// loadConfig builds the cluster config from the spec and the secrets file.
+// Fresh secrets are only generated when -new-secrets was passed.
+func loadConfig(o *options) (cluster.Config, *cluster.Secrets, secretStore, error) {
+	spec, err := cluster.LoadSpec(o.specPath)
+	if err != nil {
+		return cluster.Config{}, nil, secretStore{}, fmt.Errorf("failed to load cluster spec: %w", err)
+	}
+
+	store, err := newSecretStore(o)
+	if err != nil {
+		return cluster.Config{}, nil, secretStore{}, err
+	}
+
+	clusterSecrets, err := getClusterSecrets(store, o.newSecrets)
+	if err != nil {
+		return cluster.Config{}, nil, secretStore{}, fmt.Errorf("failed to get cluster secrets: %w", err)
+	}
+
+	// Static volume keys are minted on first use and saved with the
+	// secrets by generate.
+	if _, err := clusterSecrets.AddVolumeKeys(spec.StaticKeyIDs()); err != nil {
+		return cluster.Config{}, nil, secretStore{}, err
+	}
+
+	cfg, err := spec.Config(*clusterSecrets)
+	if err != nil {
+		return cluster.Config{}, nil, secretStore{}, fmt.Errorf("failed to create cluster config: %w", err)
+	}
+
+	return cfg, clusterSecrets, store, nil
+}
+
+func runGenerate(o *options, args []string) error {
+	if o.diff {
+		return runDiff(o, args)
+	}
+
+	cfg, clusterSecrets, store, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+
+	// Save the secrets first so configs on disk are never signed by CAs
+	// that were not persisted.
+	if err := saveClusterSecrets(store, clusterSecrets); err != nil {
+		return fmt.Errorf("failed to save cluster secrets: %w", err)
+	}
+
+	if err := writeOutputs(o, cfg, store.backup); err != nil {
+		return err
+	}
+
+	fmt.Printf("generated configs in %s\n", o.outDir)
+	return nil
+}
+
+// writeOutputs renders every config and the talosconfig and writes them to
+// the output directory, moving what was there into b.
+func writeOutputs(o *options, cfg cluster.Config, b *backup) error {
+	files, err := cfg.RenderConfigs()
+	if err != nil {
+		return fmt.Errorf("failed to generate configs: %w", err)
+	}
+	if o.merge {
+		if err := mergeTalosconfig(o, files); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(o.outDir, 0o700); err != nil {
+		return err
+	}
+	if err := performBackup(b, cfg.ClusterName()); err != nil {
+		return err
+	}
+
+	if err := cluster.WriteFiles(o.outDir, files); err != nil {
+		return fmt.Errorf("failed to write configs: %w", err)
+	}
+	return nil
+}
+
+// mergeTalosconfig replaces the rendered talosconfig in files with one
+// merged into the talosconfig already in the output directory, so contexts
+// for other clusters survive.
+func mergeTalosconfig(o *options, files []cluster.RenderedFile) error {
+	existing, err := os.ReadFile(filepath.Join(o.outDir, "config"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for i, f := range files {
+		if f.Name != "config" {
+			continue
+		}
+
+		merged, err := cluster.MergeTalosconfig(existing, f.Data, o.setCurrent)
+		if err != nil {
+			return err
+		}
+		files[i].Data = merged
+	}
+
+	return nil
+}
+
+func runSecretsInit(o *options, _ []string) error {
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(store.path); err == nil {
+		if !o.force {
+			return fmt.Errorf("%s already exists, use -force to overwrite", store.path)
+		}
+		if err := backupSecrets(store.backup, store.path); err != nil {
+			return fmt.Errorf("failed to back up secrets: %w", err)
+		}
+	}
+
+	return writeNewSecrets(store)
+}
+
+func runSecretsImport(o *options, args []string) error {
+	if len(args) != 1 {
+		return errors.New("secrets import requires the path to a talosctl secrets.yaml")
+	}
+
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(store.path); err == nil {
+		if !o.force {
+			return fmt.Errorf("%s already exists, use -force to overwrite", store.path)
+		}
+		if err := backupSecrets(store.backup, store.path); err != nil {
+			return fmt.Errorf("failed to back up secrets: %w", err)
+		}
+	}
+
+	clusterSecrets, err := importClusterSecrets(args[0], store.certs)
+	if err != nil {
+		return err
+	}
+	if err := keepExistingSecrets(store, clusterSecrets); err != nil {
+		return err
+	}
+
+	if err := saveClusterSecrets(store, clusterSecrets); err != nil {
+		return fmt.Errorf("failed to save cluster secrets: %w", err)
+	}
+
+	fmt.Printf("imported %s into %s\n", args[0], store.path)
+	return nil
+}
+
+func runSecretsExport(o *options, args []string) error {
+	if len(args) != 1 {
+		return errors.New("secrets export requires an output path")
+	}
+
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+
+	clusterSecrets, err := loadClusterSecrets(store)
+	if err != nil {
+		return fmt.Errorf("failed to load cluster secrets: %w", err)
+	}
+
+	if err := exportClusterSecrets(args[0], clusterSecrets); err != nil {
+		return err
+	}
+
+	fmt.Printf("exported secrets to %s\n", args[0])
+	return nil
+}
+
+func runSecretsRotate(o *options, _ []string) error {
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+
+	if err := backupSecrets(store.backup, store.path); err != nil {
+		return fmt.Errorf("failed to back up secrets: %w", err)
+	}
+
+	return writeNewSecrets(store)
+}
+
+func writeNewSecrets(store secretStore) error {
+	clusterSecrets, err := newClusterSecrets(store.certs)
+	if err != nil {
+		return err
+	}
+	if err := keepExistingSecrets(store, clusterSecrets); err != nil {
+		return err
+	}
+
+	if err := saveClusterSecrets(store, clusterSecrets); err != nil {
+		return fmt.Errorf("failed to save cluster secrets: %w", err)
+	}
+
+	fmt.Printf("wrote new secrets to %s\n", store.path)
+	return nil
+}
+
+func runSecretsShow(o *options, _ []string) error {
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+
+	clusterSecrets, err := loadClusterSecrets(store)
+	if err != nil {
+		return fmt.Errorf("failed to load cluster secrets: %w", err)
+	}
+
+	data, err := json.Marshal(clusterSecrets)
+	if err != nil {
+		return err
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if !o.reveal {
+		for k, v := range fields {
+			switch v := v.(type) {
+			case string:
+				if v != "" {
+					fields[k] = "<redacted>"
+				}
+			case map[string]any:
+				for id := range v {
+					v[id] = "<redacted>"
+				}
+			}
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fields)
+}
+
+func runValidate(o *options, _ []string) error {
+	if _, _, _, err := loadConfig(o); err != nil {
+		return err
+	}
+
+	fmt.Println("spec and secrets are valid")
+	return nil
+}
+
+// runDiff renders every file in memory and compares it semantically with
+// what is already in the output directory. Nothing is written. It returns
+// errChanged when any file would change.
+func runDiff(o *options, _ []string) error {
+	if o.newSecrets {
+		return errors.New("-diff cannot be combined with -new-secrets, fresh secrets change every file")
+	}
+
+	cfg, _, _, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+
+	files, err := cfg.RenderConfigs()
+	if err != nil {
+		return fmt.Errorf("failed to render configs: %w", err)
+	}
+	if o.merge {
+		if err := mergeTalosconfig(o, files); err != nil {
+			return err
+		}
+	}
+
+	changed := false
+	rendered := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		rendered[f.Name] = struct{}{}
+
+		existing, err := os.ReadFile(filepath.Join(o.outDir, f.Name))
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("added     %s\n", f.Name)
+			changed = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		changes, err := cluster.DiffConfigs(existing, f.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if len(changes) == 0 {
+			fmt.Printf("unchanged %s\n", f.Name)
+			continue
+		}
+
+		changed = true
+		fmt.Printf("changed   %s\n", f.Name)
+		for _, c := range changes {
+			fmt.Printf("    %s\n", c)
+		}
+	}
+
+	stale, err := filepath.Glob(filepath.Join(o.outDir, cfg.ClusterName()+"-*.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if _, ok := rendered[filepath.Base(path)]; !ok {
+			fmt.Printf("removed   %s\n", filepath.Base(path))
+			changed = true
+		}
+	}
+
+	if changed {
+		return errChanged
+	}
+	return nil
+}
+
+// runTalosconfig writes the admin talosconfig, or a role-scoped one with
+// -role. With -merge the context is merged into <out>/config instead.
+func runTalosconfig(o *options, _ []string) error {
+	cfg, _, store, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(o.outDir, "config")
+	context := cfg.ClusterName()
+	var data []byte
+	if o.role != "" {
+		r, err := cluster.ParseClientRole(o.role)
+		if err != nil {
+			return err
+		}
+
+		if !o.merge {
+			path = filepath.Join(o.outDir, "talosconfig-"+cluster.ClientRoleName(r))
+		}
+		context = cfg.RoleContextName(r)
+		data, err = cfg.RoleTalosconfig(r, withLifetime(store.certs.TalosClient, o.lifetime))
+		if err != nil {
+			return fmt.Errorf("failed to generate talosconfig: %w", err)
+		}
+	} else {
+		if data, err = cfg.Talosconfig(); err != nil {
+			return fmt.Errorf("failed to generate talosconfig: %w", err)
+		}
+	}
+
+	if o.merge {
+		existing, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if data, err = cluster.MergeTalosconfig(existing, data, o.setCurrent); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(o.outDir, 0o700); err != nil {
+		return err
+	}
+	if err := cluster.WriteFile(path, data); err != nil {
+		return fmt.Errorf("failed to write talosconfig: %w", err)
+	}
+
+	fmt.Printf("wrote context %s to %s\n", context, path)
+	return nil
+}
+
+func runKubeconfig(o *options, _ []string) error {
+	cfg, _, store, err := loadConfig(o)
+	if err != nil {
+		return err
+	}
+	opts := withLifetime(store.certs.Kubeconfig, o.lifetime)
+
+	if !o.merge {
+		if err := os.MkdirAll(o.outDir, 0o700); err != nil {
+			return err
+		}
+
+		path := filepath.Join(o.outDir, "kubeconfig")
+		if err := cfg.GenerateKubeconfig(path, opts); err != nil {
+			return fmt.Errorf("failed to generate kubeconfig: %w", err)
+		}
+
+		fmt.Printf("wrote %s\n", path)
+		return nil
+	}
+
+	generated, err := cfg.Kubeconfig(opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate kubeconfig: %w", err)
+	}
+
+	existing, err := os.ReadFile(o.kubeconfigPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	merged, err := cluster.MergeKubeconfig(existing, generated)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.kubeconfigPath), 0o700); err != nil {
+		return err
+	}
+	if err := cluster.WriteFile(o.kubeconfigPath, merged); err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+
+	fmt.Printf("merged %s into %s\n", cfg.ClusterName(), o.kubeconfigPath)
+	return nil
+}
+
+func runBackupList(o *options, _ []string) error {
+	backups, err := listBackups(o.outDir)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range backups {
+		fmt.Println(b)
+	}
+	return nil
+}
+
+func runBackupRestore(o *options, args []string) error {
+	if len(args) != 1 {
+		return errors.New("backup restore requires exactly one backup name")
+	}
+
+	spec, err := cluster.LoadSpec(o.specPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cluster spec: %w", err)
+	}
+
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+
+	return restoreBackup(store, spec.ClusterName, args[0])
+}
+
+// withLifetime returns opts with the -lifetime flag as their validity when
+// it was given.
+func withLifetime(opts cluster.CertOptions, lifetime time.Duration) cluster.CertOptions {
+	if lifetime != 0 {
+		opts.Validity = lifetime
+	}
+	return opts
+}
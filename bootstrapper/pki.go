@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+)
+
+// pkiDir is where intermediate Secret manifests are written, below the
+// output directory.
+const pkiDir = "pki"
+
+// runPKIIssue creates the homelab root CA on first use and issues every
+// intermediate in the spec that has none yet, or re-signs them with
+// -renew. Every intermediate is then written as a Secret manifest. The
+// root key stays in the secrets file.
+func runPKIIssue(o *options, args []string) error {
+	if len(args) > 0 && !o.renew {
+		return errors.New("intermediates can only be named with -renew")
+	}
+
+	spec, err := cluster.ReadSpec(o.specPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cluster spec: %w", err)
+	}
+	if err := spec.PKI.Validate(); err != nil {
+		return fmt.Errorf("invalid pki in spec: %w", err)
+	}
+	if len(spec.PKI.Intermediates) == 0 {
+		return errors.New("the spec declares no pki intermediates")
+	}
+	for _, name := range args {
+		if !slices.ContainsFunc(spec.PKI.Intermediates, func(im cluster.IntermediateSpec) bool { return im.Name == name }) {
+			return fmt.Errorf("unknown intermediate %q", name)
+		}
+	}
+
+	store, err := newSecretStore(o)
+	if err != nil {
+		return err
+	}
+	clusterSecrets, err := loadClusterSecrets(store)
+	if err != nil {
+		return fmt.Errorf("failed to load cluster secrets: %w", err)
+	}
+
+	created, err := clusterSecrets.EnsureRootCA(spec.PKI.Root)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Println("created the homelab root CA")
+	}
+
+	var issued []string
+	for _, im := range spec.PKI.Intermediates {
+		renew := o.renew && (len(args) == 0 || slices.Contains(args, im.Name))
+		if clusterSecrets.HasIntermediate(im.Name) && !renew {
+			continue
+		}
+		if err := clusterSecrets.IssueIntermediate(im); err != nil {
+			return err
+		}
+		issued = append(issued, im.Name)
+	}
+
+	if created || len(issued) > 0 {
+		if err := backupSecrets(store.backup, store.path); err != nil {
+			return fmt.Errorf("failed to back up secrets: %w", err)
+		}
+		if err := saveClusterSecrets(store, clusterSecrets); err != nil {
+			return fmt.Errorf("failed to save cluster secrets: %w", err)
+		}
+	}
+
+	files, err := spec.PKI.IntermediateManifests(*clusterSecrets)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(o.outDir, pkiDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := cluster.WriteFiles(dir, files); err != nil {
+		return fmt.Errorf("failed to write intermediate manifests: %w", err)
+	}
+
+	if len(issued) > 0 {
+		fmt.Printf("issued %s\n", strings.Join(issued, ", "))
+	}
+	fmt.Printf("wrote %d intermediate Secret manifest(s) to %s\n", len(files), dir)
+	return nil
+}
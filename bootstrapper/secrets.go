@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+const secretsFile = "cluster.json"
+
+// secretStore is where cluster secrets live: plaintext in the output
+// directory by default, or an age-encrypted file named by the spec. certs
+// is the spec's policy for certificates the bootstrapper issues itself.
+type secretStore struct {
+	path       string
+	backup     *backup
+	recipients []string
+	certs      cluster.CertPolicy
+}
+
+func newSecretStore(o *options) (secretStore, error) {
+	store := secretStore{
+		path:   filepath.Join(o.outDir, secretsFile),
+		backup: newBackup(o.outDir),
+	}
+
+	spec, err := cluster.ReadSpec(o.specPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return store, err
+	}
+
+	if spec.Secrets.Path != "" {
+		store.path = spec.Secrets.Path
+	}
+	store.recipients = spec.Secrets.AgeRecipients
+	store.certs = spec.Secrets.Certs
+
+	if err := spec.Secrets.Validate(); err != nil {
+		return store, fmt.Errorf("invalid secrets in spec: %w", err)
+	}
+
+	return store, nil
+}
+
+// getClusterSecrets loads the secrets file. Fresh secrets are only
+// generated when newSecrets is set; an existing file is backed up first and
+// its volume keys and homelab PKI are kept.
+func getClusterSecrets(store secretStore, newSecrets bool) (*cluster.Secrets, error) {
+	if newSecrets {
+		if err := backupSecrets(store.backup, store.path); err != nil {
+			return nil, fmt.Errorf("failed to back up secrets: %w", err)
+		}
+		cs, err := newClusterSecrets(store.certs)
+		if err != nil {
+			return nil, err
+		}
+		return cs, keepExistingSecrets(store, cs)
+	}
+
+	cs, err := loadClusterSecrets(store)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w; run with --new-secrets or `secrets init` to create one", err)
+	}
+
+	return cs, err
+}
+
+// loadClusterSecrets reads and validates the secrets file, decrypting it
+// when it is age encrypted. A file that fails to parse or validate is an
+// error, never a reason to mint new secrets.
+func loadClusterSecrets(store secretStore) (*cluster.Secrets, error) {
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := cluster.LoadAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := cluster.DecodeSecrets(data, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", store.path, err)
+	}
+
+	if err := cs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid secrets in %s: %w", store.path, err)
+	}
+
+	return &cs, nil
+}
+
+// keepExistingSecrets carries the volume keys and homelab PKI of the
+// current secrets file, if there is one, over to fresh secrets replacing it.
+func keepExistingSecrets(store secretStore, cs *cluster.Secrets) error {
+	prev, err := loadClusterSecrets(store)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load the current secrets to keep their volume keys: %w", err)
+	}
+
+	cs.KeepFrom(*prev)
+	return nil
+}
+
+func newClusterSecrets(policy cluster.CertPolicy) (*cluster.Secrets, error) {
+	bundle, err := generateClusterSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate cluster secrets: %w", err)
+	}
+
+	clusterSecrets, err := cluster.SecretsFromBundle(bundle, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterSecrets, nil
+}
+
+// importClusterSecrets builds cluster secrets from a `talosctl gen secrets`
+// file so an existing cluster keeps its CAs.
+func importClusterSecrets(path string, policy cluster.CertPolicy) (*cluster.Secrets, error) {
+	bundle, err := secrets.LoadBundle(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets bundle: %w", err)
+	}
+
+	clusterSecrets, err := cluster.SecretsFromBundle(bundle, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterSecrets, nil
+}
+
+func exportClusterSecrets(path string, cs *cluster.Secrets) error {
+	bundle, err := cs.Bundle()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets bundle: %w", err)
+	}
+
+	if err := cluster.WriteFile(path, data); err != nil {
+		return fmt.Errorf("failed to write secrets bundle: %w", err)
+	}
+	return nil
+}
+
+func generateClusterSecrets() (*secrets.Bundle, error) {
+	version, _ := config.ParseContractFromVersion("v1.6.2")
+	bundle, err := secrets.NewBundle(secrets.NewClock(), version)
+	if err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// saveClusterSecrets writes the secrets, encrypted when the store has
+// recipients. An encrypted file is never replaced by plaintext.
+func saveClusterSecrets(store secretStore, cs *cluster.Secrets) error {
+	if len(store.recipients) == 0 {
+		existing, err := os.ReadFile(store.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if cluster.IsEncrypted(existing) {
+			return fmt.Errorf("%s is age encrypted but the spec has no secrets.ageRecipients, refusing to write it in plaintext", store.path)
+		}
+	}
+
+	data, err := cluster.EncodeSecrets(*cs, store.recipients)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(store.path), 0o700); err != nil {
+		return err
+	}
+	if err := cluster.WriteFile(store.path, data); err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/failuretoload/bootstrapper/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveClusterSecretsKeepsEncryption(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	store := secretStore{
+		path:       filepath.Join(t.TempDir(), "cluster.json.age"),
+		recipients: []string{identity.Recipient().String()},
+	}
+	cs := &cluster.Secrets{Token: "test-token"}
+	require.NoError(t, saveClusterSecrets(store, cs))
+
+	store.recipients = nil
+	assert.ErrorContains(t, saveClusterSecrets(store, cs), "refusing to write it in plaintext")
+
+	data, err := os.ReadFile(store.path)
+	require.NoError(t, err)
+	assert.True(t, cluster.IsEncrypted(data))
+}
+
+func TestNewSecretsKeepVolumeKeys(t *testing.T) {
+	dir := t.TempDir()
+	store := secretStore{path: filepath.Join(dir, secretsFile), backup: newBackup(dir)}
+	require.NoError(t, writeNewSecrets(store))
+
+	prev, err := loadClusterSecrets(store)
+	require.NoError(t, err)
+	_, err = prev.AddVolumeKeys([]string{"cp1/STATE"})
+	require.NoError(t, err)
+	_, err = prev.EnsureRootCA(cluster.CertOptions{})
+	require.NoError(t, err)
+	require.NoError(t, saveClusterSecrets(store, prev))
+
+	for name, replace := range map[string]func() (*cluster.Secrets, error){
+		"secrets rotate": func() (*cluster.Secrets, error) {
+			if err := writeNewSecrets(store); err != nil {
+				return nil, err
+			}
+			return loadClusterSecrets(store)
+		},
+		"-new-secrets": func() (*cluster.Secrets, error) {
+			return getClusterSecrets(store, true)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cs, err := replace()
+			require.NoError(t, err)
+			assert.NotEqual(t, prev.OSCert, cs.OSCert)
+			assert.Equal(t, prev.VolumeKeys, cs.VolumeKeys)
+			assert.Equal(t, prev.PKI, cs.PKI)
+		})
+	}
+}
+
+func TestSecretsInitForceBacksUpSecrets(t *testing.T) {
+	dir := t.TempDir()
+	o := &options{outDir: dir, specPath: filepath.Join(dir, "cluster.yaml")}
+	require.NoError(t, runSecretsInit(o, nil))
+	assert.Error(t, runSecretsInit(o, nil))
+
+	prev, err := os.ReadFile(filepath.Join(dir, secretsFile))
+	require.NoError(t, err)
+
+	o.force = true
+	require.NoError(t, runSecretsInit(o, nil))
+
+	backups, err := listBackups(dir)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	saved, err := os.ReadFile(filepath.Join(dir, backups[0], secretsFile))
+	require.NoError(t, err)
+	assert.Equal(t, prev, saved)
+}